@@ -1,28 +1,47 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"os"
 	"path"
+	"time"
 
 	"github.com/alexflint/go-arg"
 	"github.com/lord-server/panorama/internal/config"
 	"github.com/lord-server/panorama/internal/game"
-	"github.com/lord-server/panorama/internal/generator/flat"
+	"github.com/lord-server/panorama/internal/generator"
+	"github.com/lord-server/panorama/internal/generator/rasterizer"
 	"github.com/lord-server/panorama/internal/generator/tile"
 	"github.com/lord-server/panorama/internal/server"
 	"github.com/lord-server/panorama/internal/world"
+	"github.com/lord-server/panorama/pkg/geom"
+	"github.com/lord-server/panorama/pkg/lm"
 	"github.com/lord-server/panorama/static"
 )
 
+const (
+	// Live updates are delayed to coalesce bursts of writes to the same tiles
+	liveUpdateDelay = 2 * time.Second
+	liveRetryDelay  = 10 * time.Second
+)
+
 type FullRenderArgs struct{}
 
+type IncrementalArgs struct {
+	Interval time.Duration `arg:"--interval" help:"keep re-rendering changed blocks with given interval instead of exiting"`
+}
+
 type RunArgs struct{}
 
 var args struct {
-	ConfigPath string          `arg:"-c,--config" default:"config.toml"`
-	FullRender *FullRenderArgs `arg:"subcommand:fullrender"`
-	Run        *RunArgs        `arg:"subcommand:run"`
+	ConfigPath  string           `arg:"-c,--config" default:"config.toml"`
+	Renderer    string           `arg:"--renderer" help:"renderer to use, overrides renderer mode from config"`
+	Night       bool             `arg:"--night" help:"render the night layer"`
+	FullRender  *FullRenderArgs  `arg:"subcommand:fullrender"`
+	Incremental *IncrementalArgs `arg:"subcommand:incremental"`
+	Run         *RunArgs         `arg:"subcommand:run"`
 }
 
 func main() {
@@ -34,6 +53,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	if args.Renderer != "" {
+		config.Renderer.Mode = args.Renderer
+	}
+
+	if args.Night {
+		config.Renderer.Night = true
+	}
+
+	migrateLegacyTiles(config)
+
 	switch {
 	case args.Run != nil:
 		err = run(config)
@@ -41,6 +70,9 @@ func main() {
 	case args.FullRender != nil:
 		err = fullrender(config)
 
+	case args.Incremental != nil:
+		err = incremental(config, args.Incremental.Interval)
+
 	default:
 		slog.Warn("command not specified, proceeding with run")
 
@@ -52,7 +84,26 @@ func main() {
 	}
 }
 
-func fullrender(config config.Config) error {
+// migrateLegacyTiles moves the map rendered before maps were split into layers
+// into the directory of its layer. Failures are not fatal, as the legacy map
+// only stops being served
+func migrateLegacyTiles(config config.Config) {
+	moved, conflicts, err := tile.MigrateLegacyTiles(config.System.TilesPath, generator.LegacyLayer)
+	if err != nil {
+		slog.Warn("unable to migrate legacy tiles", "error", err)
+	}
+
+	if moved > 0 {
+		slog.Info("moved legacy tiles into their layer", "layer", generator.LegacyLayer, "entries", moved)
+	}
+
+	if len(conflicts) > 0 {
+		slog.Warn("legacy tiles left in place, layer already contains them",
+			"layer", generator.LegacyLayer, "entries", conflicts)
+	}
+}
+
+func loadGameAndWorld(config config.Config) (game.Game, world.World, error) {
 	descPath := path.Join(config.System.WorldPath, "nodes_dump.json")
 
 	slog.Info("loading game description", "game", config.System.GamePath, "mods", config.System.ModPath, "desc", descPath)
@@ -60,7 +111,7 @@ func fullrender(config config.Config) error {
 	game, err := game.LoadGame(descPath, config.System.GamePath, config.System.ModPath)
 	if err != nil {
 		slog.Error("unable to load game description", "error", err)
-		return err
+		return game, world.World{}, err
 	}
 
 	wd, err := world.NewWorld(config.System.WorldPath)
@@ -72,36 +123,227 @@ func fullrender(config config.Config) error {
 		backend, err := world.NewPostgresBackend(config.System.WorldDSN)
 		if err != nil {
 			slog.Error("unable to connect to world DB", "error", err)
-			return err
+			return game, wd, err
 		}
 
 		wd = world.NewWorldWithBackend(backend)
 	}
 
-	tiler := tile.NewTiler(config.Region, config.Renderer.ZoomLevels, config.System.TilesPath)
+	return game, wd, nil
+}
+
+// rendererOptions returns options of the selected renderer
+func rendererOptions(config config.Config) rasterizer.Options {
+	sunDirection := config.Renderer.Lighting.SunDirection
+
+	options := rasterizer.Options{
+		Resolution:     config.Renderer.Resolution,
+		Night:          config.Renderer.Night,
+		SmoothLighting: config.Renderer.SmoothLighting,
+		Supersampling:  config.Renderer.Supersampling,
+		Lighting: rasterizer.Lighting{
+			SunDirection: lm.Vec3(sunDirection[0], sunDirection[1], sunDirection[2]).Normalize(),
+			Ambient:      config.Renderer.Lighting.Ambient,
+			Gamma:        config.Renderer.Lighting.Gamma,
+		},
+	}
+
+	if config.Renderer.Shadows.Enabled {
+		options.ShadowStrength = config.Renderer.Shadows.Strength
+		options.ShadowDistance = config.Renderer.Shadows.MaxDistance
+	}
+
+	return options
+}
+
+// newLayer returns a tiler writing into the tile tree of the selected renderer
+// along with a function creating renderers
+func newLayer(config config.Config, game *game.Game) (tile.Tiler, tile.CreateRendererFunc, error) {
+	options := rendererOptions(config)
+
+	createRenderer, err := generator.NewRendererFunc(config.Renderer.Mode, config.Region, game, options)
+	if err != nil {
+		slog.Error("unable to create renderer", "error", err)
+		return tile.Tiler{}, nil, err
+	}
+
+	tilesPath := path.Join(config.System.TilesPath, generator.LayerName(config.Renderer.Mode, options))
+
+	tileSize := generator.TileSize(options)
 
-	slog.Info("performing a full render", "workers", config.Renderer.Workers, "region", config.Region)
+	return tile.NewTiler(config.Region, config.Renderer.ZoomLevels, tileSize, tilesPath), createRenderer, nil
+}
 
-	tiler.FullRender(&game, &wd, config.Renderer.Workers, config.Region, func() tile.Renderer {
-		return flat.NewRenderer(config.Region, &game)
-	})
+// reportUnknownNodes warns about nodes in the world which are missing from the
+// game description, and are rendered with a placeholder texture
+func reportUnknownNodes(game *game.Game) {
+	names := game.UnknownNodes()
+	if len(names) == 0 {
+		return
+	}
+
+	slog.Warn("world contains unknown nodes, check nodes_dump and aliases", "count", len(names), "names", names)
+}
+
+func fullrender(config config.Config) error {
+	game, wd, err := loadGameAndWorld(config)
+	if err != nil {
+		return err
+	}
+
+	// Game time is read before rendering, so that blocks modified during the
+	// render, including the ones saved within the same second, get timestamps
+	// not less than the watermark and are picked up by the next incremental
+	// render
+	gameTime, gameTimeErr := world.ReadGameTime(config.System.WorldPath)
+
+	tiler, createRenderer, err := newLayer(config, &game)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("performing a full render",
+		"renderer", config.Renderer.Mode,
+		"night", config.Renderer.Night,
+		"workers", config.Renderer.Workers,
+		"region", config.Region)
+
+	tiler.FullRender(&game, &wd, config.Renderer.Workers, config.Region, createRenderer)
+	reportUnknownNodes(&game)
 
 	tiler.DownscaleTiles()
 
+	if gameTimeErr != nil {
+		slog.Warn("unable to read game time, incremental renders will start from scratch", "error", gameTimeErr)
+
+		return nil
+	}
+
+	err = tiler.SaveWatermark(gameTime)
+	if err != nil {
+		slog.Error("unable to save watermark", "error", err)
+		return err
+	}
+
 	return nil
 }
 
+func incremental(config config.Config, interval time.Duration) error {
+	game, wd, err := loadGameAndWorld(config)
+	if err != nil {
+		return err
+	}
+
+	tiler, createRenderer, err := newLayer(config, &game)
+	if err != nil {
+		return err
+	}
+
+	for {
+		err = incrementalStep(config, &game, &wd, &tiler, createRenderer)
+		if err != nil {
+			return err
+		}
+
+		if interval == 0 {
+			return nil
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func incrementalStep(
+	config config.Config,
+	game *game.Game,
+	wd *world.World,
+	tiler *tile.Tiler,
+	createRenderer tile.CreateRendererFunc,
+) error {
+	watermark, err := tiler.LoadWatermark()
+	if err != nil {
+		slog.Error("unable to load watermark", "error", err)
+		return err
+	}
+
+	gameTime, gameTimeErr := world.ReadGameTime(config.System.WorldPath)
+
+	latest, err := tiler.IncrementalRender(game, wd, config.Renderer.Workers, watermark, createRenderer)
+	if err != nil {
+		slog.Error("unable to perform an incremental render", "error", err)
+		return err
+	}
+
+	reportUnknownNodes(game)
+
+	// Saved game time lags behind block timestamps, so it's preferred over the
+	// latest timestamp: blocks saved during the render can't be missed this way
+	if gameTimeErr == nil && gameTime > watermark {
+		latest = gameTime
+	}
+
+	err = tiler.SaveWatermark(latest)
+	if err != nil {
+		slog.Error("unable to save watermark", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func live(config config.Config) error {
+	game, wd, err := loadGameAndWorld(config)
+	if err != nil {
+		return err
+	}
+
+	tiler, createRenderer, err := newLayer(config, &game)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	queue := tile.NewRenderQueue()
+
+	go tiler.RenderQueued(ctx, &game, &wd, config.Renderer.Workers, queue, liveUpdateDelay, createRenderer)
+
+	renderer := createRenderer()
+
+	slog.Info("listening for block changes")
+
+	for {
+		err = wd.ListenChanges(ctx, config.Live.InstallTrigger, func(pos geom.BlockPosition) {
+			queue.Push(tiler.AffectedTiles(renderer, pos)...)
+		})
+		if errors.Is(err, world.ErrChangesNotSupported) {
+			return err
+		}
+
+		slog.Warn("stopped listening for block changes, retrying", "error", err, "delay", liveRetryDelay)
+
+		time.Sleep(liveRetryDelay)
+	}
+}
+
 func run(config config.Config) error {
-	quit := make(chan bool)
+	quit := make(chan error)
 
 	slog.Info("starting web server", "address", config.Web.ListenAddress)
 
 	go func() {
-		server.Serve(static.UI, &config)
-		quit <- true
+		server.Serve(static.UI, &config, generator.LayerName(config.Renderer.Mode, rendererOptions(config)))
+		quit <- nil
 	}()
 
-	<-quit
+	// The map is still served when live updates can't be followed
+	if config.Live.Enabled {
+		go func() {
+			err := live(config)
+			if err != nil {
+				slog.Error("live updates are disabled", "error", err)
+			}
+		}()
+	}
 
-	return nil
+	return <-quit
 }
@@ -13,9 +13,28 @@ type Web struct {
 	Title         string `toml:"title"`
 }
 
+type Shadows struct {
+	Enabled     bool    `toml:"enabled"`
+	Strength    float64 `toml:"strength"`
+	MaxDistance int     `toml:"max_distance"`
+}
+
+type Lighting struct {
+	SunDirection [3]float64 `toml:"sun_direction"`
+	Ambient      float64    `toml:"ambient"`
+	Gamma        float64    `toml:"gamma"`
+}
+
 type Renderer struct {
-	Workers    int `toml:"workers"`
-	ZoomLevels int `toml:"zoom_levels"`
+	Mode           string   `toml:"mode"`
+	Resolution     int      `toml:"resolution"`
+	Night          bool     `toml:"night"`
+	SmoothLighting bool     `toml:"smooth_lighting"`
+	Supersampling  int      `toml:"supersampling"`
+	Workers        int      `toml:"workers"`
+	ZoomLevels     int      `toml:"zoom_levels"`
+	Shadows        Shadows  `toml:"shadows"`
+	Lighting       Lighting `toml:"lighting"`
 }
 
 type System struct {
@@ -26,15 +45,39 @@ type System struct {
 	WorldDSN  string `toml:"world_dsn"`
 }
 
+type Live struct {
+	Enabled        bool `toml:"enabled"`
+	InstallTrigger bool `toml:"install_trigger"`
+}
+
 type Config struct {
 	System   System      `toml:"system"`
 	Web      Web         `toml:"web"`
 	Renderer Renderer    `toml:"renderer"`
+	Live     Live        `toml:"live"`
 	Region   geom.Region `toml:"region"`
 }
 
 func LoadConfig(path string) (Config, error) {
-	var config Config
+	config := Config{
+		Renderer: Renderer{
+			Mode:          "flat",
+			Resolution:    16,
+			Supersampling: 1,
+			Shadows: Shadows{
+				Strength:    0.5,
+				MaxDistance: 64,
+			},
+			Lighting: Lighting{
+				SunDirection: [3]float64{-0.5, 1, -0.8},
+				Ambient:      0.2,
+				Gamma:        2.2,
+			},
+		},
+		Live: Live{
+			InstallTrigger: true,
+		},
+	}
 
 	file, err := os.Open(path)
 	if err != nil {
@@ -3,7 +3,12 @@ package game
 import (
 	"encoding/json"
 	"image"
+	"image/color"
+	"log/slog"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/lord-server/panorama/pkg/mesh"
 )
@@ -19,12 +24,108 @@ type NodeDefinition struct {
 	ParamType2 ParamType2
 	Textures   []*image.NRGBA
 	Model      *mesh.Model
+	Palette    *image.NRGBA
+
+	VisualScale float64
+	Groups      map[string]int
+	LightSource int
+
+	// Nodes which boxes depend on param2 or neighbors keep their box
+	// descriptions and tiles to build a model at render time
+	NodeBox      *NodeBox
+	Tiles        []*image.NRGBA
+	ConnectsTo   map[string]bool
+	ConnectSides uint8
+	Leveled      int
+
+	SpecialTiles  []*image.NRGBA
+	LiquidSource  string
+	LiquidFlowing string
+	LiquidRange   int
+}
+
+// PaletteIndex extracts the palette index from param2 of a node
+func (n *NodeDefinition) PaletteIndex(param2 uint8) uint8 {
+	switch n.ParamType2 {
+	case ParamType2Color:
+		return param2
+	case ParamType2ColorFaceDir, ParamType2ColorDegRotate:
+		return param2 >> 5
+	case ParamType2ColorWallMounted:
+		return param2 >> 3
+	case ParamType2ColorFourDir:
+		return param2 >> 2
+	default:
+		return 0
+	}
+}
+
+// PaletteColor returns the color textures are multiplied by. Pixels of the
+// palette are indexed row by row, nodes without a palette and indices outside
+// of it are white.
+func (n *NodeDefinition) PaletteColor(index uint8) color.NRGBA {
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+
+	if n.Palette == nil {
+		return white
+	}
+
+	width := n.Palette.Rect.Dx()
+	if int(index) >= width*n.Palette.Rect.Dy() {
+		return white
+	}
+
+	return n.Palette.NRGBAAt(n.Palette.Rect.Min.X+int(index)%width, n.Palette.Rect.Min.Y+int(index)/width)
 }
 
 type Game struct {
 	Aliases map[string]string
 	Nodes   map[string]NodeDefinition
 	unknown NodeDefinition
+
+	unknownNames *unknownNames
+}
+
+// unknownNames collects names found in the world which resolve neither to a
+// node nor to an alias
+type unknownNames struct {
+	mutex    sync.Mutex
+	names    map[string]bool
+	reported map[string]bool
+}
+
+func newUnknownNames() *unknownNames {
+	return &unknownNames{
+		names:    make(map[string]bool),
+		reported: make(map[string]bool),
+	}
+}
+
+func (u *unknownNames) add(name string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if !u.reported[name] {
+		u.names[name] = true
+	}
+}
+
+// take returns sorted names collected since the previous call
+func (u *unknownNames) take() []string {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	names := make([]string, 0, len(u.names))
+	for name := range u.names {
+		names = append(names, name)
+		u.reported[name] = true
+	}
+
+	u.names = make(map[string]bool)
+
+	sort.Strings(names)
+
+	return names
 }
 
 func makeNormalNode(drawtype DrawType, tiles []*image.NRGBA) NodeDefinition {
@@ -56,34 +157,13 @@ func makeNormalNode(drawtype DrawType, tiles []*image.NRGBA) NodeDefinition {
 }
 
 func makeNodeBox(nodeBox *NodeBox, tiles []*image.NRGBA) NodeDefinition {
-	textures := make([]*image.NRGBA, 6*len(nodeBox.Fixed))
-	model := mesh.NewModel()
-
-	if len(tiles) == 0 {
-		return NodeDefinition{
-			Textures: textures,
-			Model:    &model,
-		}
-	}
-
-	for _, box := range nodeBox.Fixed {
-		model.Meshes = append(model.Meshes, mesh.Cuboid(box[0], box[1], box[2], box[3], box[4], box[5], mesh.CubeFaceNone)...)
-	}
-
-	for i := 0; i < len(nodeBox.Fixed); i++ {
-		for j := 0; j < 6; j++ {
-			if j >= len(tiles) {
-				textures[6*i+j] = tiles[len(tiles)-1]
-				continue
-			}
-
-			textures[6*i+j] = tiles[j]
-		}
-	}
+	model, textures := BoxesModel(nodeBox.Fixed, tiles)
 
 	return NodeDefinition{
 		Textures: textures,
-		Model:    &model,
+		Model:    model,
+		NodeBox:  nodeBox,
+		Tiles:    tiles,
 	}
 }
 
@@ -110,18 +190,58 @@ func makeMeshNode(model *mesh.Model, tiles []*image.NRGBA) NodeDefinition {
 	}
 }
 
-func ResolveNode(descriptor NodeDescriptor, mediaCache *MediaCache) NodeDefinition {
-	tiles := make([]*image.NRGBA, len(descriptor.Tiles))
+func makePlantlikeNode(tiles []*image.NRGBA, visualScale float64) NodeDefinition {
+	textures := make([]*image.NRGBA, 1)
+	if len(tiles) != 0 {
+		textures[0] = tiles[0]
+	}
 
-	for i, tileName := range descriptor.Tiles {
-		tiles[i] = mediaCache.Image(tileName)
+	return NodeDefinition{
+		Textures: textures,
+		Model:    mesh.Plantlike(mesh.PlantStyleCross, visualScale/2, 1),
 	}
+}
+
+// makeGeneratedNode creates a node which mesh depends on its param2 and
+// neighbors. Tiles are repeated the same way as for normal nodes, and the model
+// is only a default variant.
+func makeGeneratedNode(model *mesh.Model, tiles []*image.NRGBA) NodeDefinition {
+	textures := make([]*image.NRGBA, 6)
+
+	for i := range textures {
+		if len(tiles) == 0 {
+			break
+		}
+
+		textures[i] = tiles[min(i, len(tiles)-1)]
+	}
+
+	return NodeDefinition{
+		Textures: textures,
+		Model:    model,
+	}
+}
+
+func ResolveNode(descriptor NodeDescriptor, mediaCache *MediaCache) NodeDefinition {
+	tiles := resolveTiles(descriptor.Tiles, descriptor.OverlayTiles, descriptor.Color, mediaCache)
 
 	var nd NodeDefinition
 
 	switch descriptor.DrawType {
 	case DrawTypeNormal, DrawTypeAllFaces, DrawTypeLiquid, DrawTypeFlowingLiquid, DrawTypeGlasslike, DrawTypeGlasslikeFramed:
 		nd = makeNormalNode(descriptor.DrawType, tiles)
+	case DrawTypePlantlike:
+		nd = makePlantlikeNode(tiles, descriptor.VisualScale)
+	case DrawTypeTorchlike:
+		nd = makeGeneratedNode(mesh.Torchlike(mesh.WallMountedFloor, descriptor.VisualScale/2), tiles)
+	case DrawTypeSignlike:
+		nd = makeGeneratedNode(mesh.Signlike(mesh.WallMountedFloor, descriptor.VisualScale/2), tiles)
+	case DrawTypeFirelike:
+		nd = makeGeneratedNode(mesh.Firelike(0, descriptor.VisualScale/2), tiles)
+	case DrawTypeRaillike:
+		nd = makeGeneratedNode(mesh.Raillike(0, false), tiles)
+	case DrawTypeFencelike:
+		nd = makeNodeBox(&NodeBox{Fixed: fenceBoxes(0)}, tiles[:min(len(tiles), 1)])
 	case DrawTypeNodeBox:
 		if descriptor.NodeBox == nil {
 			break
@@ -142,10 +262,86 @@ func ResolveNode(descriptor NodeDescriptor, mediaCache *MediaCache) NodeDefiniti
 	nd.DrawType = descriptor.DrawType
 	nd.ParamType = descriptor.ParamType
 	nd.ParamType2 = descriptor.ParamType2
+	nd.VisualScale = descriptor.VisualScale
+	nd.Groups = descriptor.Groups
+	nd.LightSource = descriptor.LightSource
+	nd.Leveled = descriptor.Leveled
+	nd.LiquidSource = descriptor.LiquidAlternativeSource
+	nd.LiquidFlowing = descriptor.LiquidAlternativeFlowing
+	nd.LiquidRange = descriptor.LiquidRange
+	nd.SpecialTiles = resolveTiles(descriptor.SpecialTiles, nil, descriptor.Color, mediaCache)
+
+	for _, side := range descriptor.ConnectSides {
+		nd.ConnectSides |= ConnectionNames[side]
+	}
+
+	if descriptor.Palette != "" {
+		nd.Palette = mediaCache.Image(descriptor.Palette)
+	}
 
 	return nd
 }
 
+// resolveConnections expands groups in `connects_to` of node descriptors into
+// sets of node names
+func resolveConnections(descriptors map[string]NodeDescriptor, nodes map[string]NodeDefinition) {
+	for name, descriptor := range descriptors {
+		if len(descriptor.ConnectsTo) == 0 {
+			continue
+		}
+
+		connectsTo := make(map[string]bool)
+
+		for _, target := range descriptor.ConnectsTo {
+			group, isGroup := strings.CutPrefix(target, "group:")
+			if !isGroup {
+				connectsTo[target] = true
+				continue
+			}
+
+			for otherName, other := range descriptors {
+				if other.Groups[group] > 0 {
+					connectsTo[otherName] = true
+				}
+			}
+		}
+
+		node := nodes[name]
+		node.ConnectsTo = connectsTo
+		nodes[name] = node
+	}
+}
+
+// resolveAliases follows chains of aliases, so that every alias points
+// directly to a node. Aliases which don't lead to a node are dropped.
+func resolveAliases(aliases map[string]string, nodes map[string]NodeDefinition) map[string]string {
+	resolved := make(map[string]string, len(aliases))
+
+	for alias := range aliases {
+		name := alias
+		visited := map[string]bool{}
+
+		for !visited[name] {
+			visited[name] = true
+
+			target, ok := aliases[name]
+			if !ok {
+				break
+			}
+
+			name = target
+		}
+
+		if _, ok := nodes[name]; ok {
+			resolved[alias] = name
+		} else {
+			slog.Warn("alias doesn't resolve to a node", "alias", alias, "target", aliases[alias])
+		}
+	}
+
+	return resolved
+}
+
 func LoadGame(desc string, path string, modpath string) (Game, error) {
 	descJSON, err := os.ReadFile(desc)
 	if err != nil {
@@ -179,14 +375,17 @@ func LoadGame(desc string, path string, modpath string) (Game, error) {
 		nodes[name] = node
 	}
 
+	resolveConnections(descriptor.Nodes, nodes)
+
 	return Game{
-		Aliases: descriptor.Aliases,
+		Aliases: resolveAliases(descriptor.Aliases, nodes),
 		Nodes:   nodes,
 		unknown: NodeDefinition{
 			DrawType: DrawTypeNormal,
 			Textures: []*image.NRGBA{mediaCache.dummyImage},
 			Model:    nil,
 		},
+		unknownNames: newUnknownNames(),
 	}, nil
 }
 
@@ -195,5 +394,23 @@ func (g *Game) NodeDef(node string) NodeDefinition {
 		return def
 	}
 
+	if def, ok := g.Nodes[g.Aliases[node]]; ok {
+		return def
+	}
+
+	if node != "air" && node != "ignore" && g.unknownNames != nil {
+		g.unknownNames.add(node)
+	}
+
 	return g.unknown
 }
+
+// UnknownNodes returns sorted names of nodes without a definition, which were
+// looked up since the previous call
+func (g *Game) UnknownNodes() []string {
+	if g.unknownNames == nil {
+		return nil
+	}
+
+	return g.unknownNames.take()
+}
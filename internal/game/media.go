@@ -6,7 +6,6 @@ import (
 	"io/fs"
 	"log/slog"
 	"path/filepath"
-	"strings"
 
 	"github.com/lord-server/panorama/pkg/imageutil"
 	"github.com/lord-server/panorama/pkg/mesh"
@@ -14,6 +13,7 @@ import (
 
 type MediaCache struct {
 	images     map[string]*image.NRGBA
+	textures   map[string]*image.NRGBA
 	models     map[string]*mesh.Model
 	dummyImage *image.NRGBA
 }
@@ -28,6 +28,7 @@ func NewMediaCache() *MediaCache {
 
 	return &MediaCache{
 		images:     make(map[string]*image.NRGBA),
+		textures:   make(map[string]*image.NRGBA),
 		models:     make(map[string]*mesh.Model),
 		dummyImage: dummyImage,
 	}
@@ -49,13 +50,21 @@ func (m *MediaCache) fetchMedia(path string) error {
 
 		switch filepath.Ext(path) {
 		case ".png":
-			img, _ := imageutil.LoadPNG(path)
+			img, err := imageutil.LoadPNG(path)
+			if err != nil {
+				slog.Warn("unable to load image", "path", path, "error", err)
+
+				return nil
+			}
+
 			m.images[basePath] = img
 
-		case ".obj":
-			model, err := mesh.LoadOBJ(path)
+		case ".obj", ".b3d", ".gltf", ".glb":
+			model, err := mesh.Load(path)
 			if err != nil {
-				return err
+				slog.Warn("unable to load mesh", "path", path, "error", err)
+
+				return nil
 			}
 
 			m.models[basePath] = &model
@@ -65,16 +74,19 @@ func (m *MediaCache) fetchMedia(path string) error {
 	})
 }
 
+// Image returns the image described by the texture string, which may contain
+// texture modifiers. Returned images are shared and must not be modified.
 func (m *MediaCache) Image(name string) *image.NRGBA {
-	// FIXME: resolve modifiers
-	baseName := strings.Split(name, "^")[0]
+	img, err := m.texture(name)
+	if err != nil {
+		slog.Warn("unable to load image", "name", name, "error", err)
+
+		m.textures[name] = m.dummyImage
 
-	if img, ok := m.images[baseName]; ok {
-		return img
-	} else {
-		slog.Warn("unknown image", "name", name)
 		return m.dummyImage
 	}
+
+	return img
 }
 
 func (m *MediaCache) Mesh(name string) *mesh.Model {
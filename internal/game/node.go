@@ -3,6 +3,7 @@ package game
 import (
 	"encoding/json"
 	"fmt"
+	"math/bits"
 )
 
 type DrawType int
@@ -39,14 +40,13 @@ var DrawTypeNames = map[string]DrawType{
 	"allfaces_optional":         DrawTypeAllFaces,
 	"torchlike":                 DrawTypeTorchlike,
 	"signlike":                  DrawTypeSignlike,
-	//	"plantlike":                 DrawTypePlantlike,
-	"plantlike":        DrawTypeAllFaces,
-	"firelike":         DrawTypeFirelike,
-	"fencelike":        DrawTypeFencelike,
-	"raillike":         DrawTypeRaillike,
-	"nodebox":          DrawTypeNodeBox,
-	"mesh":             DrawTypeMesh,
-	"plantlike_rooted": DrawTypePlantlikeRooted,
+	"plantlike":                 DrawTypePlantlike,
+	"firelike":                  DrawTypeFirelike,
+	"fencelike":                 DrawTypeFencelike,
+	"raillike":                  DrawTypeRaillike,
+	"nodebox":                   DrawTypeNodeBox,
+	"mesh":                      DrawTypeMesh,
+	"plantlike_rooted":          DrawTypePlantlikeRooted,
 }
 
 func (t DrawType) IsLiquid() bool {
@@ -115,6 +115,8 @@ const (
 	ParamType2ColorDegRotate
 	ParamType2None
 	ParamType2Waving
+	ParamType2FourDir
+	ParamType2ColorFourDir
 )
 
 var ParamType2Names = map[string]ParamType2{
@@ -130,6 +132,8 @@ var ParamType2Names = map[string]ParamType2{
 	"glasslikeliquidlevel": ParamType2GlassLikeLiquidLevel,
 	"colordegrotate":       ParamType2ColorDegRotate,
 	"waving":               ParamType2Waving,
+	"4dir":                 ParamType2FourDir,
+	"color4dir":            ParamType2ColorFourDir,
 	"none":                 ParamType2None,
 }
 
@@ -150,78 +154,141 @@ func (t *ParamType2) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// Connection directions of connected nodeboxes
+const (
+	ConnectTop uint8 = 1 << iota
+	ConnectBottom
+	ConnectFront
+	ConnectLeft
+	ConnectBack
+	ConnectRight
+
+	ConnectSides = ConnectFront | ConnectLeft | ConnectBack | ConnectRight
+)
+
+var ConnectionNames = map[string]uint8{
+	"top":    ConnectTop,
+	"bottom": ConnectBottom,
+	"front":  ConnectFront,
+	"left":   ConnectLeft,
+	"back":   ConnectBack,
+	"right":  ConnectRight,
+}
+
 type NodeBox struct {
 	Type  string
 	Fixed [][]float64
-}
 
-func (n *NodeBox) UnmarshalJSON(data []byte) error {
-	type nodeBox struct {
-		Type  string        `json:"type"`
-		Fixed []interface{} `json:"fixed"`
-	}
+	// Boxes of wallmounted nodeboxes
+	WallTop    [][]float64
+	WallBottom [][]float64
+	WallSide   [][]float64
 
-	inner := &nodeBox{}
-	if err := json.Unmarshal(data, inner); err != nil {
-		return err
-	}
+	// Boxes of connected nodeboxes, indexed by the connection bit
+	Connect      [6][][]float64
+	Disconnected [6][][]float64
 
-	n.Type = inner.Type
-	n.Fixed = make([][]float64, 0)
+	// Boxes used when there are no connections at all, or no connections to
+	// the sides
+	DisconnectedAll   [][]float64
+	DisconnectedSides [][]float64
+}
 
-	if inner.Type != "fixed" {
-		return nil
+// parseBoxes accepts both a single box and a list of boxes
+func parseBoxes(value interface{}) [][]float64 {
+	items, ok := value.([]interface{})
+	if !ok || len(items) == 0 {
+		return [][]float64{}
 	}
 
-	if len(inner.Fixed) == 0 {
-		return nil
+	if _, ok := items[0].([]interface{}); !ok {
+		items = []interface{}{items}
 	}
 
-	if _, ok := inner.Fixed[0].(float64); ok {
-		box := make([]float64, 0)
+	boxes := make([][]float64, 0, len(items))
 
-		for i := 0; i < 6; i++ {
-			v, _ := inner.Fixed[i].(float64)
-			box = append(box, v)
+	for _, item := range items {
+		coords, ok := item.([]interface{})
+		if !ok || len(coords) < 6 {
+			continue
 		}
 
-		n.Fixed = append(n.Fixed, box)
+		box := make([]float64, 6)
+
+		for i := range box {
+			box[i], _ = coords[i].(float64)
+		}
+
+		boxes = append(boxes, box)
+	}
+
+	return boxes
+}
+
+func (n *NodeBox) UnmarshalJSON(data []byte) error {
+	var inner map[string]interface{}
+	if err := json.Unmarshal(data, &inner); err != nil {
+		return err
 	}
 
-	if _, ok := inner.Fixed[0].([]interface{}); ok {
-		for _, boxInterface := range inner.Fixed {
-			boxFloat64 := boxInterface.([]interface{})
-			box := make([]float64, 0)
+	n.Type, _ = inner["type"].(string)
+	n.Fixed = parseBoxes(inner["fixed"])
+	n.WallTop = parseBoxes(inner["wall_top"])
+	n.WallBottom = parseBoxes(inner["wall_bottom"])
+	n.WallSide = parseBoxes(inner["wall_side"])
+	n.DisconnectedAll = parseBoxes(inner["disconnected"])
+	n.DisconnectedSides = parseBoxes(inner["disconnected_sides"])
 
-			for i := 0; i < 6; i++ {
-				v, _ := boxFloat64[i].(float64)
-				box = append(box, v)
-			}
+	for name, bit := range ConnectionNames {
+		index := bits.TrailingZeros8(bit)
 
-			n.Fixed = append(n.Fixed, box)
-		}
+		n.Connect[index] = parseBoxes(inner["connect_"+name])
+		n.Disconnected[index] = parseBoxes(inner["disconnected_"+name])
 	}
 
 	return nil
 }
 
+// Flowing liquids store their level in lower bits of param2
+const (
+	LiquidLevelMax  = 7
+	LiquidLevelMask = 0x07
+)
+
 type NodeDescriptor struct {
-	DrawType   DrawType   `json:"drawtype"`
-	ParamType  ParamType  `json:"paramtype"`
-	ParamType2 ParamType2 `json:"paramtype2"`
-	Tiles      []string   `json:"tiles"`
-	NodeBox    *NodeBox   `json:"node_box"`
-	Mesh       *string    `json:"mesh"`
+	DrawType    DrawType         `json:"drawtype"`
+	ParamType   ParamType        `json:"paramtype"`
+	ParamType2  ParamType2       `json:"paramtype2"`
+	Tiles       []TileDescriptor `json:"tiles"`
+	NodeBox     *NodeBox         `json:"node_box"`
+	Mesh        *string          `json:"mesh"`
+	Palette     string           `json:"palette"`
+	VisualScale float64          `json:"visual_scale"`
+	Groups      map[string]int   `json:"groups"`
+	LightSource int              `json:"light_source"`
+
+	ConnectsTo   []string `json:"connects_to"`
+	ConnectSides []string `json:"connect_sides"`
+	Leveled      int      `json:"leveled"`
+
+	OverlayTiles             []TileDescriptor `json:"overlay_tiles"`
+	SpecialTiles             []TileDescriptor `json:"special_tiles"`
+	Color                    *ColorSpec       `json:"color"`
+	LiquidAlternativeFlowing string           `json:"liquid_alternative_flowing"`
+	LiquidAlternativeSource  string           `json:"liquid_alternative_source"`
+	LiquidRange              int              `json:"liquid_range"`
 }
 
 func (n *NodeDescriptor) UnmarshalJSON(data []byte) error {
 	type nodeDescriptor NodeDescriptor
 
 	inner := &nodeDescriptor{
-		DrawType:   DrawTypeNormal,
-		Tiles:      []string{},
-		ParamType:  ParamTypeLight,
-		ParamType2: ParamType2None,
+		DrawType:    DrawTypeNormal,
+		Tiles:       []TileDescriptor{},
+		ParamType:   ParamTypeLight,
+		ParamType2:  ParamType2None,
+		VisualScale: 1,
+		LiquidRange: LiquidLevelMax + 1,
 	}
 
 	if err := json.Unmarshal(data, inner); err != nil {
@@ -0,0 +1,182 @@
+package game
+
+import (
+	"image"
+	"math"
+
+	"github.com/lord-server/panorama/pkg/lm"
+	"github.com/lord-server/panorama/pkg/mesh"
+)
+
+const (
+	leveledMask = 0x7F
+	leveledMax  = 127
+
+	fencePostRadius = 1.0 / 8
+	fenceBarRadius  = 1.0 / 16
+)
+
+// WallMounted returns the surface the node is attached to. Nodes which can't
+// be mounted stand on the floor.
+func (n *NodeDefinition) WallMounted(param2 uint8) mesh.WallMounted {
+	switch n.ParamType2 {
+	case ParamType2WallMounted, ParamType2ColorWallMounted:
+		return mesh.WallMounted(param2 & 0x7)
+	default:
+		return mesh.WallMountedFloor
+	}
+}
+
+// Level returns the level of a leveled node, measured in 1/64 of the node
+func (n *NodeDefinition) Level(param2 uint8) int {
+	if n.ParamType2 == ParamType2Leveled {
+		if level := int(param2 & leveledMask); level != 0 {
+			return level
+		}
+	}
+
+	return min(n.Leveled, leveledMax)
+}
+
+// rotateBoxXZ rotates the box around the Y axis by given angle in degrees
+func rotateBoxXZ(box []float64, angle float64) []float64 {
+	radians := lm.Radians(angle)
+	a := lm.Vec3(box[0], box[1], box[2]).RotateXZ(radians)
+	b := lm.Vec3(box[3], box[4], box[5]).RotateXZ(radians)
+
+	return []float64{
+		math.Min(a.X, b.X), math.Min(a.Y, b.Y), math.Min(a.Z, b.Z),
+		math.Max(a.X, b.X), math.Max(a.Y, b.Y), math.Max(a.Z, b.Z),
+	}
+}
+
+func (n *NodeDefinition) wallMountedBoxes(param2 uint8) [][]float64 {
+	switch wall := n.WallMounted(param2); wall {
+	case mesh.WallMountedCeiling, mesh.WallMountedCeilingRotated:
+		return n.NodeBox.WallTop
+	case mesh.WallMountedFloor, mesh.WallMountedFloorRotated:
+		return n.NodeBox.WallBottom
+	default:
+		// Side boxes are defined for the wall at -X
+		angles := map[mesh.WallMounted]float64{
+			mesh.WallMountedEast:  180,
+			mesh.WallMountedWest:  0,
+			mesh.WallMountedNorth: -90,
+			mesh.WallMountedSouth: 90,
+		}
+
+		boxes := make([][]float64, len(n.NodeBox.WallSide))
+		for i, box := range n.NodeBox.WallSide {
+			boxes[i] = rotateBoxXZ(box, angles[wall])
+		}
+
+		return boxes
+	}
+}
+
+func (n *NodeDefinition) connectedBoxes(connections uint8) [][]float64 {
+	boxes := append([][]float64{}, n.NodeBox.Fixed...)
+
+	for i := range n.NodeBox.Connect {
+		if connections&(1<<i) != 0 {
+			boxes = append(boxes, n.NodeBox.Connect[i]...)
+		} else {
+			boxes = append(boxes, n.NodeBox.Disconnected[i]...)
+		}
+	}
+
+	if connections == 0 {
+		boxes = append(boxes, n.NodeBox.DisconnectedAll...)
+	}
+
+	if connections&ConnectSides == 0 {
+		boxes = append(boxes, n.NodeBox.DisconnectedSides...)
+	}
+
+	return boxes
+}
+
+// fenceBoxes returns a post with bars leading towards connected neighbors.
+// Each node draws only its half of the bars, so that the geometry doesn't
+// leave its position.
+func fenceBoxes(connections uint8) [][]float64 {
+	boxes := [][]float64{
+		{-fencePostRadius, -0.5, -fencePostRadius, fencePostRadius, 0.5, fencePostRadius},
+	}
+
+	// Bars leading towards +X, rotated for other directions
+	bars := map[uint8]float64{
+		ConnectRight: 0,
+		ConnectBack:  90,
+		ConnectLeft:  180,
+		ConnectFront: -90,
+	}
+
+	for direction, angle := range bars {
+		if connections&direction == 0 {
+			continue
+		}
+
+		for _, y := range []float64{0.25, -0.25} {
+			bar := []float64{fencePostRadius, y - fenceBarRadius, -fenceBarRadius, 0.5, y + fenceBarRadius, fenceBarRadius}
+			boxes = append(boxes, rotateBoxXZ(bar, angle))
+		}
+	}
+
+	return boxes
+}
+
+// Boxes returns boxes the node consists of, given its param2 and connections
+// to neighbors
+func (n *NodeDefinition) Boxes(param2, connections uint8) [][]float64 {
+	if n.DrawType == DrawTypeFencelike {
+		return fenceBoxes(connections)
+	}
+
+	if n.NodeBox == nil {
+		return nil
+	}
+
+	switch n.NodeBox.Type {
+	case "leveled":
+		top := -0.5 + float64(n.Level(param2))/64
+		boxes := make([][]float64, len(n.NodeBox.Fixed))
+
+		for i, box := range n.NodeBox.Fixed {
+			boxes[i] = []float64{box[0], box[1], box[2], box[3], top, box[5]}
+		}
+
+		return boxes
+
+	case "wallmounted":
+		return n.wallMountedBoxes(param2)
+
+	case "connected":
+		return n.connectedBoxes(connections)
+
+	default:
+		return n.NodeBox.Fixed
+	}
+}
+
+// BoxesModel creates a model of the boxes along with textures of its meshes.
+// Tiles are applied to faces in the order of top, bottom, right, left, back
+// and front.
+func BoxesModel(boxes [][]float64, tiles []*image.NRGBA) (*mesh.Model, []*image.NRGBA) {
+	model := mesh.NewModel()
+	textures := make([]*image.NRGBA, 0, 6*len(boxes))
+
+	for _, box := range boxes {
+		model.Meshes = append(model.Meshes, mesh.Cuboid(box[0], box[1], box[2], box[3], box[4], box[5], mesh.CubeFaceNone)...)
+
+		for j := 0; j < 6; j++ {
+			if len(tiles) == 0 {
+				textures = append(textures, nil)
+			} else {
+				textures = append(textures, tiles[min(j, len(tiles)-1)])
+			}
+		}
+	}
+
+	return &model, textures
+}
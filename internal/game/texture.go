@@ -0,0 +1,763 @@
+package game
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+	"strings"
+
+	"github.com/lord-server/panorama/pkg/imageutil"
+)
+
+// Textures are described using Minetest texture modifier language, e.g.
+// `default_dirt.png^(default_grass_side.png^[mask:mask.png)^[colorize:red:64`.
+// Parts are separated with `^`: file names are overlaid on top of the image
+// composed so far, and modifiers starting with `[` transform it. Parentheses
+// group parts, and backslash escapes the next character.
+
+var errNoBaseImage = errors.New("modifier requires a base image")
+
+type textureModifier func(m *MediaCache, base *image.NRGBA, args []string) (*image.NRGBA, error)
+
+// splitTexture splits the texture string at separators which are neither
+// escaped nor enclosed in parentheses. Escapes are preserved.
+func splitTexture(s string, sep byte) []string {
+	var parts []string
+
+	depth := 0
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// unescapeTexture removes a single level of escaping
+func unescapeTexture(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var builder strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+
+		builder.WriteByte(s[i])
+	}
+
+	return builder.String()
+}
+
+// texture returns the composed image described by the texture string. Results
+// are cached and must not be modified.
+func (m *MediaCache) texture(name string) (*image.NRGBA, error) {
+	if img, ok := m.textures[name]; ok {
+		return img, nil
+	}
+
+	var img *image.NRGBA
+
+	for _, part := range splitTexture(name, '^') {
+		var err error
+
+		img, err = m.applyTexturePart(img, part)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if img == nil {
+		return nil, fmt.Errorf("empty texture: `%s`", name)
+	}
+
+	m.textures[name] = img
+
+	return img, nil
+}
+
+func (m *MediaCache) applyTexturePart(base *image.NRGBA, part string) (*image.NRGBA, error) {
+	if strings.HasPrefix(part, "[") {
+		return m.applyModifier(base, part)
+	}
+
+	var (
+		overlay *image.NRGBA
+		err     error
+	)
+
+	if strings.HasPrefix(part, "(") && strings.HasSuffix(part, ")") {
+		overlay, err = m.texture(part[1 : len(part)-1])
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var ok bool
+
+		// Images which failed to load are missing as well
+		overlay, ok = m.images[unescapeTexture(part)]
+		if !ok || overlay == nil {
+			return nil, fmt.Errorf("unknown image: `%s`", part)
+		}
+	}
+
+	if base == nil {
+		return overlay, nil
+	}
+
+	return overlayTexture(base, overlay), nil
+}
+
+// overlayTexture blends the overlay over the base image. If their sizes
+// differ, the smaller image is upscaled to the size of the larger one.
+func overlayTexture(base, overlay *image.NRGBA) *image.NRGBA {
+	baseSize := base.Rect.Size()
+	overlaySize := overlay.Rect.Size()
+
+	var result *image.NRGBA
+
+	switch {
+	case baseSize == overlaySize:
+		result = imageutil.Clone(base)
+	case baseSize.X*baseSize.Y < overlaySize.X*overlaySize.Y:
+		result = imageutil.Scale(base, overlaySize.X, overlaySize.Y)
+	default:
+		result = imageutil.Clone(base)
+		overlay = imageutil.Scale(overlay, baseSize.X, baseSize.Y)
+	}
+
+	imageutil.BlendOver(result, overlay, image.Point{})
+
+	return result
+}
+
+func (m *MediaCache) applyModifier(base *image.NRGBA, part string) (*image.NRGBA, error) {
+	args := splitTexture(part[1:], ':')
+	name := args[0]
+
+	// Transformations are appended to the modifier name without a separator
+	if strings.HasPrefix(name, "transform") {
+		if base == nil {
+			return nil, errNoBaseImage
+		}
+
+		return transformTexture(base, name[len("transform"):])
+	}
+
+	modifier := lookupModifier(name)
+	if modifier == nil {
+		return nil, fmt.Errorf("unsupported texture modifier: `[%s`", name)
+	}
+
+	img, err := modifier(m, base, args[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid texture modifier `%s`: %w", part, err)
+	}
+
+	return img, nil
+}
+
+// lookupModifier returns the implementation of the named modifier. A switch is
+// used instead of a map, since modifiers evaluate textures recursively.
+func lookupModifier(name string) textureModifier {
+	switch name {
+	case "brighten":
+		return modifyBrighten
+	case "colorize":
+		return modifyColorize
+	case "combine":
+		return modifyCombine
+	case "crack", "cracko":
+		return modifyNothing
+	case "fill":
+		return modifyFill
+	case "invert":
+		return modifyInvert
+	case "lowpart":
+		return modifyLowPart
+	case "makealpha":
+		return modifyMakeAlpha
+	case "mask":
+		return modifyMask
+	case "multiply":
+		return modifyMultiply
+	case "noalpha":
+		return modifyNoAlpha
+	case "opacity":
+		return modifyOpacity
+	case "png":
+		return modifyPNG
+	case "resize":
+		return modifyResize
+	case "sheet":
+		return modifySheet
+	case "verticalframe":
+		return modifyVerticalFrame
+	}
+
+	return nil
+}
+
+func parseSize(s string) (int, int, error) {
+	width, height, ok := strings.Cut(s, "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid size: `%s`", s)
+	}
+
+	w, err := strconv.Atoi(width)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	h, err := strconv.Atoi(height)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if w <= 0 || h <= 0 {
+		return 0, 0, fmt.Errorf("invalid size: `%s`", s)
+	}
+
+	return w, h, nil
+}
+
+func parsePoint(s string) (image.Point, error) {
+	x, y, ok := strings.Cut(s, ",")
+	if !ok {
+		return image.Point{}, fmt.Errorf("invalid position: `%s`", s)
+	}
+
+	px, err := strconv.Atoi(x)
+	if err != nil {
+		return image.Point{}, err
+	}
+
+	py, err := strconv.Atoi(y)
+	if err != nil {
+		return image.Point{}, err
+	}
+
+	return image.Point{X: px, Y: py}, nil
+}
+
+func parseByte(s string) (uint8, error) {
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint8(max(0, min(value, 255))), nil
+}
+
+func checkArgs(args []string, count int) error {
+	if len(args) < count {
+		return fmt.Errorf("expected %d arguments, got %d", count, len(args))
+	}
+
+	return nil
+}
+
+func modifyNothing(_ *MediaCache, base *image.NRGBA, _ []string) (*image.NRGBA, error) {
+	if base == nil {
+		return nil, errNoBaseImage
+	}
+
+	return base, nil
+}
+
+func modifyBrighten(_ *MediaCache, base *image.NRGBA, _ []string) (*image.NRGBA, error) {
+	if base == nil {
+		return nil, errNoBaseImage
+	}
+
+	return imageutil.MapColors(base, func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{R: 127 + c.R/2, G: 127 + c.G/2, B: 127 + c.B/2, A: c.A}
+	}), nil
+}
+
+func modifyNoAlpha(_ *MediaCache, base *image.NRGBA, _ []string) (*image.NRGBA, error) {
+	if base == nil {
+		return nil, errNoBaseImage
+	}
+
+	return imageutil.MapColors(base, func(c color.NRGBA) color.NRGBA {
+		c.A = 255
+		return c
+	}), nil
+}
+
+// [colorize:<color>:<ratio>
+func modifyColorize(_ *MediaCache, base *image.NRGBA, args []string) (*image.NRGBA, error) {
+	if base == nil {
+		return nil, errNoBaseImage
+	}
+
+	if err := checkArgs(args, 1); err != nil {
+		return nil, err
+	}
+
+	tint, err := ParseColor(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// Without explicit ratio, alpha of the color is used instead
+	ratio := -1
+	keepAlpha := false
+
+	if len(args) > 1 {
+		if args[1] == "alpha" {
+			keepAlpha = true
+		} else {
+			value, err := parseByte(args[1])
+			if err != nil {
+				return nil, err
+			}
+
+			ratio = int(value)
+		}
+	}
+
+	// With `alpha` ratio, alpha of the color is multiplied by alpha of pixels
+	if keepAlpha || (ratio == -1 && tint.A == 255) || ratio == 255 {
+		return imageutil.MapColors(base, func(c color.NRGBA) color.NRGBA {
+			if c.A == 0 {
+				return c
+			}
+
+			if keepAlpha {
+				return color.NRGBA{R: tint.R, G: tint.G, B: tint.B, A: uint8(int(c.A) * int(tint.A) / 255)}
+			}
+
+			return tint
+		}), nil
+	}
+
+	factor := float64(ratio) / 255
+	if ratio == -1 {
+		factor = float64(tint.A) / 255
+	}
+
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(a)*factor + float64(b)*(1-factor))
+	}
+
+	return imageutil.MapColors(base, func(c color.NRGBA) color.NRGBA {
+		if c.A == 0 {
+			return c
+		}
+
+		return color.NRGBA{R: mix(tint.R, c.R), G: mix(tint.G, c.G), B: mix(tint.B, c.B), A: mix(tint.A, c.A)}
+	}), nil
+}
+
+// [multiply:<color>
+func modifyMultiply(_ *MediaCache, base *image.NRGBA, args []string) (*image.NRGBA, error) {
+	if base == nil {
+		return nil, errNoBaseImage
+	}
+
+	if err := checkArgs(args, 1); err != nil {
+		return nil, err
+	}
+
+	tint, err := ParseColor(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return imageutil.MapColors(base, func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{
+			R: uint8(int(c.R) * int(tint.R) / 255),
+			G: uint8(int(c.G) * int(tint.G) / 255),
+			B: uint8(int(c.B) * int(tint.B) / 255),
+			A: c.A,
+		}
+	}), nil
+}
+
+// [opacity:<ratio>
+func modifyOpacity(_ *MediaCache, base *image.NRGBA, args []string) (*image.NRGBA, error) {
+	if base == nil {
+		return nil, errNoBaseImage
+	}
+
+	if err := checkArgs(args, 1); err != nil {
+		return nil, err
+	}
+
+	opacity, err := parseByte(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return imageutil.MapColors(base, func(c color.NRGBA) color.NRGBA {
+		c.A = uint8(int(c.A) * int(opacity) / 255)
+		return c
+	}), nil
+}
+
+// [invert:<mode>, where mode contains channels to invert, e.g. `rgb`
+func modifyInvert(_ *MediaCache, base *image.NRGBA, args []string) (*image.NRGBA, error) {
+	if base == nil {
+		return nil, errNoBaseImage
+	}
+
+	if err := checkArgs(args, 1); err != nil {
+		return nil, err
+	}
+
+	mode := args[0]
+
+	invert := func(channel rune, value uint8) uint8 {
+		if strings.ContainsRune(mode, channel) {
+			return 255 - value
+		}
+
+		return value
+	}
+
+	return imageutil.MapColors(base, func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{R: invert('r', c.R), G: invert('g', c.G), B: invert('b', c.B), A: invert('a', c.A)}
+	}), nil
+}
+
+// [makealpha:<r>,<g>,<b>
+func modifyMakeAlpha(_ *MediaCache, base *image.NRGBA, args []string) (*image.NRGBA, error) {
+	if base == nil {
+		return nil, errNoBaseImage
+	}
+
+	if err := checkArgs(args, 1); err != nil {
+		return nil, err
+	}
+
+	channels := strings.Split(args[0], ",")
+	if len(channels) != 3 {
+		return nil, fmt.Errorf("invalid color: `%s`", args[0])
+	}
+
+	var key [3]uint8
+
+	for i, channel := range channels {
+		value, err := parseByte(channel)
+		if err != nil {
+			return nil, err
+		}
+
+		key[i] = value
+	}
+
+	return imageutil.MapColors(base, func(c color.NRGBA) color.NRGBA {
+		if c.R == key[0] && c.G == key[1] && c.B == key[2] {
+			c.A = 0
+		}
+
+		return c
+	}), nil
+}
+
+// [resize:<w>x<h>
+func modifyResize(_ *MediaCache, base *image.NRGBA, args []string) (*image.NRGBA, error) {
+	if base == nil {
+		return nil, errNoBaseImage
+	}
+
+	if err := checkArgs(args, 1); err != nil {
+		return nil, err
+	}
+
+	width, height, err := parseSize(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return imageutil.Scale(base, width, height), nil
+}
+
+// [sheet:<w>x<h>:<x>,<y> extracts a single tile of a sheet
+func modifySheet(_ *MediaCache, base *image.NRGBA, args []string) (*image.NRGBA, error) {
+	if base == nil {
+		return nil, errNoBaseImage
+	}
+
+	if err := checkArgs(args, 2); err != nil {
+		return nil, err
+	}
+
+	columns, rows, err := parseSize(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	cell, err := parsePoint(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	width := max(1, base.Rect.Dx()/columns)
+	height := max(1, base.Rect.Dy()/rows)
+	origin := image.Point{X: (cell.X % columns) * width, Y: (cell.Y % rows) * height}
+
+	return imageutil.Crop(base, image.Rectangle{Min: origin, Max: origin.Add(image.Point{X: width, Y: height})}), nil
+}
+
+// [verticalframe:<count>:<frame> extracts a single frame of an animation
+func modifyVerticalFrame(_ *MediaCache, base *image.NRGBA, args []string) (*image.NRGBA, error) {
+	if base == nil {
+		return nil, errNoBaseImage
+	}
+
+	if err := checkArgs(args, 2); err != nil {
+		return nil, err
+	}
+
+	count, err := strconv.Atoi(args[0])
+	if err != nil || count <= 0 {
+		return nil, fmt.Errorf("invalid frame count: `%s`", args[0])
+	}
+
+	frame, err := strconv.Atoi(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	height := max(1, base.Rect.Dy()/count)
+	rect := image.Rect(0, 0, base.Rect.Dx(), height).Add(image.Point{Y: (frame % count) * height})
+
+	return imageutil.Crop(base, rect), nil
+}
+
+// [mask:<texture> keeps bits present in both images
+func modifyMask(m *MediaCache, base *image.NRGBA, args []string) (*image.NRGBA, error) {
+	if base == nil {
+		return nil, errNoBaseImage
+	}
+
+	mask, err := m.texture(unescapeTexture(strings.Join(args, ":")))
+	if err != nil {
+		return nil, err
+	}
+
+	result := imageutil.Clone(base)
+	rect := result.Rect.Intersect(mask.Rect.Sub(mask.Rect.Min))
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			c := result.NRGBAAt(x, y)
+			mc := mask.NRGBAAt(mask.Rect.Min.X+x, mask.Rect.Min.Y+y)
+
+			result.SetNRGBA(x, y, color.NRGBA{R: c.R & mc.R, G: c.G & mc.G, B: c.B & mc.B, A: c.A & mc.A})
+		}
+	}
+
+	return result, nil
+}
+
+// [lowpart:<percent>:<texture> overlays lower part of the texture
+func modifyLowPart(m *MediaCache, base *image.NRGBA, args []string) (*image.NRGBA, error) {
+	if base == nil {
+		return nil, errNoBaseImage
+	}
+
+	if err := checkArgs(args, 2); err != nil {
+		return nil, err
+	}
+
+	percent, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	overlay, err := m.texture(unescapeTexture(strings.Join(args[1:], ":")))
+	if err != nil {
+		return nil, err
+	}
+
+	result := imageutil.Clone(base)
+	overlay = imageutil.Scale(overlay, result.Rect.Dx(), result.Rect.Dy())
+
+	top := result.Rect.Dy() - result.Rect.Dy()*max(0, min(percent, 100))/100
+	part := imageutil.Crop(overlay, image.Rect(0, top, overlay.Rect.Dx(), overlay.Rect.Dy()))
+
+	imageutil.BlendOver(result, part, image.Point{Y: top})
+
+	return result, nil
+}
+
+// [combine:<w>x<h>:<x>,<y>=<texture>:... blits textures at given positions
+func modifyCombine(m *MediaCache, base *image.NRGBA, args []string) (*image.NRGBA, error) {
+	if err := checkArgs(args, 1); err != nil {
+		return nil, err
+	}
+
+	width, height, err := parseSize(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var result *image.NRGBA
+	if base == nil {
+		result = image.NewNRGBA(image.Rect(0, 0, width, height))
+	} else {
+		result = imageutil.Clone(base)
+	}
+
+	for _, arg := range args[1:] {
+		pos, name, ok := strings.Cut(arg, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid combine entry: `%s`", arg)
+		}
+
+		at, err := parsePoint(pos)
+		if err != nil {
+			return nil, err
+		}
+
+		img, err := m.texture(unescapeTexture(name))
+		if err != nil {
+			return nil, err
+		}
+
+		imageutil.BlendOver(result, img, at)
+	}
+
+	return result, nil
+}
+
+// [fill:<w>x<h>:<x>,<y>:<color> or [fill:<w>x<h>:<color>
+func modifyFill(_ *MediaCache, base *image.NRGBA, args []string) (*image.NRGBA, error) {
+	if err := checkArgs(args, 2); err != nil {
+		return nil, err
+	}
+
+	width, height, err := parseSize(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var at image.Point
+
+	if len(args) > 2 {
+		at, err = parsePoint(args[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fill, err := ParseColor(args[len(args)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	var result *image.NRGBA
+	if base == nil {
+		result = image.NewNRGBA(image.Rect(0, 0, width, height))
+	} else {
+		result = imageutil.Clone(base)
+	}
+
+	rect := image.Rect(0, 0, width, height).Add(at)
+	draw.Draw(result, rect, image.NewUniform(fill), image.Point{}, draw.Src)
+
+	return result, nil
+}
+
+// [png:<base64> embeds a PNG image
+func modifyPNG(_ *MediaCache, _ *image.NRGBA, args []string) (*image.NRGBA, error) {
+	if err := checkArgs(args, 1); err != nil {
+		return nil, err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	result := image.NewNRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(result, result.Rect, img, img.Bounds().Min, draw.Src)
+
+	return result, nil
+}
+
+// Transformations, numbered as in Minetest. Rotations are counter-clockwise.
+var textureTransforms = []struct {
+	name  string
+	apply func(*image.NRGBA) *image.NRGBA
+}{
+	{"I", func(img *image.NRGBA) *image.NRGBA { return img }},
+	{"R90", imageutil.Rotate90},
+	{"R180", func(img *image.NRGBA) *image.NRGBA { return imageutil.Rotate90(imageutil.Rotate90(img)) }},
+	{"R270", func(img *image.NRGBA) *image.NRGBA {
+		return imageutil.Rotate90(imageutil.Rotate90(imageutil.Rotate90(img)))
+	}},
+	{"FX", imageutil.FlipX},
+	{"FXR90", func(img *image.NRGBA) *image.NRGBA { return imageutil.Rotate90(imageutil.FlipX(img)) }},
+	{"FY", imageutil.FlipY},
+	{"FYR90", func(img *image.NRGBA) *image.NRGBA { return imageutil.Rotate90(imageutil.FlipY(img)) }},
+}
+
+// transformTexture applies a sequence of transformations, each given either
+// by its number or name, e.g. `FXR90` or `4R90`
+func transformTexture(img *image.NRGBA, spec string) (*image.NRGBA, error) {
+	spec = strings.ToUpper(spec)
+
+	for spec != "" {
+		if spec[0] >= '0' && spec[0] <= '7' {
+			img = textureTransforms[spec[0]-'0'].apply(img)
+			spec = spec[1:]
+
+			continue
+		}
+
+		matched := ""
+
+		var apply func(*image.NRGBA) *image.NRGBA
+
+		// The longest matching name wins, so that `FXR90` isn't parsed as `FX`
+		for _, transform := range textureTransforms {
+			if strings.HasPrefix(spec, transform.name) && len(transform.name) > len(matched) {
+				matched = transform.name
+				apply = transform.apply
+			}
+		}
+
+		if matched == "" {
+			return nil, fmt.Errorf("invalid texture transformation: `%s`", spec)
+		}
+
+		img = apply(img)
+		spec = spec[len(matched):]
+	}
+
+	return img, nil
+}
@@ -0,0 +1,245 @@
+package game
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"slices"
+	"testing"
+)
+
+var (
+	red         = color.NRGBA{R: 255, A: 255}
+	green       = color.NRGBA{G: 255, A: 255}
+	blue        = color.NRGBA{B: 255, A: 255}
+	white       = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	transparent = color.NRGBA{}
+)
+
+// newTestImage returns an image of given width filled row by row with pixels
+func newTestImage(width int, pixels ...color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, len(pixels)/width))
+
+	for i, c := range pixels {
+		img.SetNRGBA(i%width, i/width, c)
+	}
+
+	return img
+}
+
+func newTestMediaCache() *MediaCache {
+	m := NewMediaCache()
+
+	m.images["red.png"] = newTestImage(2, red, red, red, red)
+	m.images["quad.png"] = newTestImage(2, red, green, blue, white)
+	m.images["mask.png"] = newTestImage(2, white, white, transparent, transparent)
+	m.images["transparent.png"] = newTestImage(2, transparent, transparent, transparent, transparent)
+	m.images["dot.png"] = newTestImage(1, blue)
+	m.images["odd^name:.png"] = newTestImage(1, green)
+	m.images["broken.png"] = nil
+
+	return m
+}
+
+// pixels returns colors of the image row by row
+func pixels(img *image.NRGBA) []color.NRGBA {
+	var result []color.NRGBA
+
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			result = append(result, img.NRGBAAt(x, y))
+		}
+	}
+
+	return result
+}
+
+func encodePNG(t *testing.T, img image.Image) string {
+	var buf bytes.Buffer
+
+	err := png.Encode(&buf, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestSplitTexture(t *testing.T) {
+	tests := []struct {
+		texture string
+		sep     byte
+		want    []string
+	}{
+		{"a.png", '^', []string{"a.png"}},
+		{"a.png^b.png^[brighten", '^', []string{"a.png", "b.png", "[brighten"}},
+		{"a.png^(b.png^[mask:c.png)", '^', []string{"a.png", "(b.png^[mask:c.png)"}},
+		{"a.png^((b.png^c.png)^d.png)", '^', []string{"a.png", "((b.png^c.png)^d.png)"}},
+		{`a\^b.png^c.png`, '^', []string{`a\^b.png`, "c.png"}},
+		{`[combine:2x2:0,0=a.png\^[mask\:b.png`, '^', []string{`[combine:2x2:0,0=a.png\^[mask\:b.png`}},
+		{`combine:2x2:0,0=a.png\^[mask\:b.png:1,1=c.png`, ':', []string{
+			"combine", "2x2", `0,0=a.png\^[mask\:b.png`, "1,1=c.png",
+		}},
+		{"combine:2x2:0,0=(a.png^[mask:b.png)", ':', []string{"combine", "2x2", "0,0=(a.png^[mask:b.png)"}},
+		{"a.png^", '^', []string{"a.png", ""}},
+	}
+
+	for _, test := range tests {
+		got := splitTexture(test.texture, test.sep)
+		if !slices.Equal(got, test.want) {
+			t.Errorf("splitTexture(%q, %q) = %q, want %q", test.texture, test.sep, got, test.want)
+		}
+	}
+}
+
+func TestUnescapeTexture(t *testing.T) {
+	tests := []struct {
+		texture string
+		want    string
+	}{
+		{"a.png", "a.png"},
+		{`a.png\^[mask\:b.png`, "a.png^[mask:b.png"},
+		{`a.png\\^b.png`, `a.png\^b.png`},
+		{`a.png\`, `a.png\`},
+	}
+
+	for _, test := range tests {
+		got := unescapeTexture(test.texture)
+		if got != test.want {
+			t.Errorf("unescapeTexture(%q) = %q, want %q", test.texture, got, test.want)
+		}
+	}
+}
+
+func TestTexture(t *testing.T) {
+	embedded := encodePNG(t, newTestImage(1, green))
+
+	tests := []struct {
+		texture string
+		width   int
+		want    []color.NRGBA
+	}{
+		// Overlays and grouping
+		{"quad.png", 2, []color.NRGBA{red, green, blue, white}},
+		{"red.png^dot.png", 2, []color.NRGBA{blue, blue, blue, blue}},
+		{"dot.png^mask.png", 2, []color.NRGBA{white, white, blue, blue}},
+		{"red.png^(quad.png^[mask:mask.png)", 2, []color.NRGBA{red, green, red, red}},
+		{`odd\^name\:.png`, 1, []color.NRGBA{green}},
+
+		// Color modifiers
+		{"red.png^[brighten", 2, []color.NRGBA{
+			{R: 254, G: 127, B: 127, A: 255}, {R: 254, G: 127, B: 127, A: 255},
+			{R: 254, G: 127, B: 127, A: 255}, {R: 254, G: 127, B: 127, A: 255},
+		}},
+		{"transparent.png^[noalpha", 2, []color.NRGBA{
+			{A: 255}, {A: 255}, {A: 255}, {A: 255},
+		}},
+		{"quad.png^[colorize:#0000ff:255", 2, []color.NRGBA{blue, blue, blue, blue}},
+		{"mask.png^[colorize:red", 2, []color.NRGBA{red, red, transparent, transparent}},
+		{"mask.png^[colorize:#ff000080:alpha", 2, []color.NRGBA{
+			{R: 255, A: 128}, {R: 255, A: 128}, transparent, transparent,
+		}},
+		{"red.png^[colorize:blue:0", 2, []color.NRGBA{red, red, red, red}},
+		{"quad.png^[multiply:#808080", 2, []color.NRGBA{
+			{R: 128, A: 255}, {G: 128, A: 255}, {B: 128, A: 255}, {R: 128, G: 128, B: 128, A: 255},
+		}},
+		{"red.png^[opacity:51", 2, []color.NRGBA{
+			{R: 255, A: 51}, {R: 255, A: 51}, {R: 255, A: 51}, {R: 255, A: 51},
+		}},
+		{"quad.png^[invert:rg", 2, []color.NRGBA{green, red, white, blue}},
+		{"mask.png^[invert:a", 2, []color.NRGBA{
+			{R: 255, G: 255, B: 255}, {R: 255, G: 255, B: 255}, {A: 255}, {A: 255},
+		}},
+		{"quad.png^[makealpha:255,0,0", 2, []color.NRGBA{{R: 255}, green, blue, white}},
+		{"quad.png^[crack:1:2", 2, []color.NRGBA{red, green, blue, white}},
+
+		// Geometry modifiers
+		{"dot.png^[resize:2x3", 2, []color.NRGBA{blue, blue, blue, blue, blue, blue}},
+		{"quad.png^[sheet:2x2:1,1", 1, []color.NRGBA{white}},
+		{"quad.png^[sheet:2x2:0,1", 1, []color.NRGBA{blue}},
+		{"quad.png^[verticalframe:2:1", 2, []color.NRGBA{blue, white}},
+		{"quad.png^[lowpart:50:dot.png", 2, []color.NRGBA{red, green, blue, blue}},
+
+		// Masks
+		{"quad.png^[mask:mask.png", 2, []color.NRGBA{red, green, transparent, transparent}},
+		{"quad.png^[mask:(mask.png^[invert:a)", 2, []color.NRGBA{
+			{R: 255}, {G: 255}, {A: 255}, {A: 255},
+		}},
+
+		// Generated images
+		{"[combine:2x2:1,0=dot.png:0,1=red.png", 2, []color.NRGBA{transparent, blue, red, red}},
+		{"quad.png^[combine:2x2:0,0=dot.png", 2, []color.NRGBA{blue, green, blue, white}},
+		{`[combine:2x2:0,0=red.png\^[combine\:1x1\:0,0=dot.png`, 2, []color.NRGBA{blue, red, red, red}},
+		{"[combine:2x2:0,0=(red.png^[mask:mask.png)", 2, []color.NRGBA{red, red, transparent, transparent}},
+		{`[combine:1x1:0,0=odd\\\^name\\\:.png`, 1, []color.NRGBA{green}},
+		{"[fill:2x1:red", 2, []color.NRGBA{red, red}},
+		{"quad.png^[fill:1x1:1,1:#00ff00", 2, []color.NRGBA{red, green, blue, green}},
+		{"[png:" + embedded, 1, []color.NRGBA{green}},
+
+		// Transformations, rotations are counter-clockwise
+		{"quad.png^[transform0", 2, []color.NRGBA{red, green, blue, white}},
+		{"quad.png^[transformR90", 2, []color.NRGBA{green, white, red, blue}},
+		{"quad.png^[transform1", 2, []color.NRGBA{green, white, red, blue}},
+		{"quad.png^[transformR180", 2, []color.NRGBA{white, blue, green, red}},
+		{"quad.png^[transformR270", 2, []color.NRGBA{blue, red, white, green}},
+		{"quad.png^[transformFX", 2, []color.NRGBA{green, red, white, blue}},
+		{"quad.png^[transform4", 2, []color.NRGBA{green, red, white, blue}},
+		{"quad.png^[transformfy", 2, []color.NRGBA{blue, white, red, green}},
+		{"quad.png^[transformFXR90", 2, []color.NRGBA{red, blue, green, white}},
+		{"quad.png^[transform5", 2, []color.NRGBA{red, blue, green, white}},
+		{"quad.png^[transformFYR90", 2, []color.NRGBA{white, green, blue, red}},
+		{"quad.png^[transform4R90", 2, []color.NRGBA{red, blue, green, white}},
+		{"quad.png^[transformR90R90", 2, []color.NRGBA{white, blue, green, red}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.texture, func(t *testing.T) {
+			img, err := newTestMediaCache().texture(test.texture)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if width := img.Rect.Dx(); width != test.width {
+				t.Fatalf("width = %d, want %d", width, test.width)
+			}
+
+			if got := pixels(img); !slices.Equal(got, test.want) {
+				t.Errorf("pixels = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTextureErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"missing.png",
+		"quad.png^missing.png",
+		"broken.png",
+		"quad.png^broken.png",
+		"[combine:2x2:0,0=broken.png",
+		"[brighten",
+		"[transformR90",
+		"quad.png^[unknown",
+		"quad.png^[transformR45",
+		"quad.png^[colorize:nocolor",
+		"quad.png^[sheet:2x2",
+		"quad.png^[sheet:0x2:0,0",
+		"quad.png^[verticalframe:0:0",
+		"quad.png^[mask:missing.png",
+		"quad.png^[makealpha:255,0",
+		"[combine:2x2:0,0",
+		"[combine:2x2:0,0=missing.png",
+		"[combine:2xa",
+		"[png:not base64",
+	}
+
+	for _, texture := range tests {
+		_, err := newTestMediaCache().texture(texture)
+		if err == nil {
+			t.Errorf("texture(%q) succeeded, want an error", texture)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/lord-server/panorama/pkg/imageutil"
+)
+
+// ColorSpec is a color given either as a ColorString, as an ARGB number or as
+// a table of components
+type ColorSpec color.NRGBA
+
+func (c *ColorSpec) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		parsed, err := ParseColor(s)
+		if err != nil {
+			return err
+		}
+
+		*c = ColorSpec(parsed)
+
+		return nil
+	}
+
+	var argb uint32
+	if err := json.Unmarshal(data, &argb); err == nil {
+		*c = ColorSpec{A: uint8(argb >> 24), R: uint8(argb >> 16), G: uint8(argb >> 8), B: uint8(argb)}
+		return nil
+	}
+
+	components := struct {
+		A *uint8 `json:"a"`
+		R uint8  `json:"r"`
+		G uint8  `json:"g"`
+		B uint8  `json:"b"`
+	}{}
+
+	if err := json.Unmarshal(data, &components); err != nil {
+		return fmt.Errorf("invalid color: %s", data)
+	}
+
+	*c = ColorSpec{R: components.R, G: components.G, B: components.B, A: 255}
+	if components.A != nil {
+		c.A = *components.A
+	}
+
+	return nil
+}
+
+// TileAnimation describes an animated texture, only its first frame is
+// rendered
+type TileAnimation struct {
+	Type    string `json:"type"`
+	AspectW int    `json:"aspect_w"`
+	AspectH int    `json:"aspect_h"`
+	FramesW int    `json:"frames_w"`
+	FramesH int    `json:"frames_h"`
+}
+
+// firstFrame crops the first frame of an animated texture
+func (a *TileAnimation) firstFrame(img *image.NRGBA) *image.NRGBA {
+	width := img.Rect.Dx()
+	height := img.Rect.Dy()
+
+	switch {
+	case a.Type == "vertical_frames" && a.AspectW > 0 && a.AspectH > 0:
+		height = min(height, width*a.AspectH/a.AspectW)
+	case a.Type == "sheet_2d" && a.FramesW > 0 && a.FramesH > 0:
+		width /= a.FramesW
+		height /= a.FramesH
+	default:
+		return img
+	}
+
+	if width <= 0 || height <= 0 {
+		return img
+	}
+
+	return imageutil.Crop(img, image.Rect(0, 0, width, height))
+}
+
+// TileDescriptor describes a single tile, which may be given either as a
+// texture name or as a table
+type TileDescriptor struct {
+	Name            string         `json:"name"`
+	Image           string         `json:"image"`
+	Animation       *TileAnimation `json:"animation"`
+	Color           *ColorSpec     `json:"color"`
+	BackfaceCulling *bool          `json:"backface_culling"`
+	AlignStyle      string         `json:"align_style"`
+	Scale           int            `json:"scale"`
+}
+
+func (t *TileDescriptor) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = TileDescriptor{Name: name}
+		return nil
+	}
+
+	type tileDescriptor TileDescriptor
+
+	inner := &tileDescriptor{}
+	if err := json.Unmarshal(data, inner); err != nil {
+		return err
+	}
+
+	// `image` is a deprecated name of the field
+	if inner.Name == "" {
+		inner.Name = inner.Image
+	}
+
+	*t = TileDescriptor(*inner)
+
+	return nil
+}
+
+// multiplyColor returns a copy of the image multiplied by the color
+func multiplyColor(img *image.NRGBA, c ColorSpec) *image.NRGBA {
+	if c == (ColorSpec{R: 255, G: 255, B: 255, A: 255}) {
+		return img
+	}
+
+	return imageutil.MapColors(img, func(p color.NRGBA) color.NRGBA {
+		return color.NRGBA{
+			R: uint8(uint16(p.R) * uint16(c.R) / 255),
+			G: uint8(uint16(p.G) * uint16(c.G) / 255),
+			B: uint8(uint16(p.B) * uint16(c.B) / 255),
+			A: uint8(uint16(p.A) * uint16(c.A) / 255),
+		}
+	})
+}
+
+// Tile returns the first frame of the tile texture multiplied by its color,
+// or by the default color if the tile doesn't specify one
+func (m *MediaCache) Tile(tile TileDescriptor, defaultColor *ColorSpec) *image.NRGBA {
+	img := m.Image(tile.Name)
+
+	if tile.Animation != nil {
+		img = tile.Animation.firstFrame(img)
+	}
+
+	switch {
+	case tile.Color != nil:
+		img = multiplyColor(img, *tile.Color)
+	case defaultColor != nil:
+		img = multiplyColor(img, *defaultColor)
+	}
+
+	return img
+}
+
+// resolveTiles loads tiles of a node and composites overlay tiles over them.
+// Both lists repeat their last tile when one of them is shorter. Overlays
+// aren't colored by the node color.
+func resolveTiles(tiles, overlays []TileDescriptor, nodeColor *ColorSpec, m *MediaCache) []*image.NRGBA {
+	if len(tiles) == 0 {
+		return nil
+	}
+
+	result := make([]*image.NRGBA, max(len(tiles), len(overlays)))
+
+	for i := range result {
+		result[i] = m.Tile(tiles[min(i, len(tiles)-1)], nodeColor)
+
+		if len(overlays) == 0 {
+			continue
+		}
+
+		overlay := overlays[min(i, len(overlays)-1)]
+		if overlay.Name == "" {
+			continue
+		}
+
+		result[i] = overlayTexture(result[i], m.Tile(overlay, nil))
+	}
+
+	return result
+}
@@ -2,6 +2,7 @@ package flat
 
 import (
 	"image"
+	"image/color"
 	"log/slog"
 
 	"github.com/lord-server/panorama/internal/game"
@@ -12,53 +13,337 @@ import (
 	"github.com/lord-server/panorama/pkg/lm"
 )
 
+const (
+	// How far below the liquid surface the renderer looks for its bottom
+	maxLiquidDepth = 16
+
+	// Brightness change per node of height difference with neighboring columns
+	reliefShading = 0.06
+	maxRelief     = 4
+)
+
+// column describes the topmost visible node of a single node column
+type column struct {
+	present     bool
+	height      int
+	surface     *game.NodeDefinition
+	surfaceTint color.NRGBA
+
+	// liquid covering the surface, if any
+	liquid      *game.NodeDefinition
+	liquidTint  color.NRGBA
+	liquidDepth int
+}
+
+// blockColumn contains columns of a single block column indexed by X and Z
+type blockColumn [geom.BlockSize][geom.BlockSize]column
+
 type FlatRenderer struct {
-	nr rasterizer.NodeRasterizer
+	region     geom.Region
+	game       *game.Game
+	resolution int
 
-	region geom.Region
-	game   *game.Game
+	averageColors map[*image.NRGBA]color.NRGBA
 }
 
-func NewRenderer(region geom.Region, game *game.Game) *FlatRenderer {
+// NewRenderer creates a flat renderer. Flat maps don't use node lighting, so
+// only the resolution is taken from options.
+func NewRenderer(region geom.Region, game *game.Game, options rasterizer.Options) *FlatRenderer {
 	return &FlatRenderer{
-		nr:     rasterizer.New(lm.DimetricProjection()),
-		region: region,
-		game:   game,
+		region:        region,
+		game:          game,
+		resolution:    options.Resolution,
+		averageColors: make(map[*image.NRGBA]color.NRGBA),
 	}
 }
 
-func (r *FlatRenderer) RenderTile(
-	tilePos tile.TilePosition,
-	wd *world.World,
-	game *game.Game,
-) *rasterizer.RenderBuffer {
-	rect := image.Rect(0, 0, 256, 256)
-	target := rasterizer.NewRenderBuffer(rect)
+func (r *FlatRenderer) isVisible(nodeDef *game.NodeDefinition) bool {
+	return nodeDef.DrawType != game.DrawTypeAirlike && len(nodeDef.Textures) != 0 && nodeDef.Textures[0] != nil
+}
+
+// scanColumns finds topmost visible nodes in every node column of given block
+// column
+func (r *FlatRenderer) scanColumns(wd *world.World, blockX, blockZ int) *blockColumn {
+	var blocks []*world.MapBlock
+
+	var blockYs []int
 
 	selector := world.BlocksAlongY{
-		X: tilePos.X,
-		Z: tilePos.Y,
+		X:    blockX,
+		Z:    blockZ,
+		MinY: lm.FloorDiv(r.region.YBounds.Min, geom.BlockSize),
+		MaxY: lm.FloorDiv(r.region.YBounds.Max, geom.BlockSize),
 	}
 
 	err := wd.GetBlocks(selector, func(pos geom.BlockPosition, block *world.MapBlock) error {
+		blocks = append(blocks, block)
+		blockYs = append(blockYs, pos.Y)
+
 		return nil
 	})
 	if err != nil {
 		slog.Error("unable to get blocks", "error", err)
 	}
 
+	columns := &blockColumn{}
+
+	for z := 0; z < geom.BlockSize; z++ {
+		worldZ := blockZ*geom.BlockSize + z
+		if worldZ < r.region.ZBounds.Min || worldZ > r.region.ZBounds.Max {
+			continue
+		}
+
+		for x := 0; x < geom.BlockSize; x++ {
+			worldX := blockX*geom.BlockSize + x
+			if worldX < r.region.XBounds.Min || worldX > r.region.XBounds.Max {
+				continue
+			}
+
+			columns[z][x] = r.scanColumn(blocks, blockYs, x, z)
+		}
+	}
+
+	return columns
+}
+
+func (r *FlatRenderer) scanColumn(blocks []*world.MapBlock, blockYs []int, x, z int) column {
+	var result column
+
+	// Blocks are ordered by Y, so they are traversed backwards to go top-down
+	for i := len(blocks) - 1; i >= 0; i-- {
+		for y := geom.BlockSize - 1; y >= 0; y-- {
+			worldY := blockYs[i]*geom.BlockSize + y
+			if worldY < r.region.YBounds.Min || worldY > r.region.YBounds.Max {
+				continue
+			}
+
+			if result.liquid != nil && result.height-worldY >= maxLiquidDepth {
+				result.liquidDepth = maxLiquidDepth
+
+				return result
+			}
+
+			node := blocks[i].GetNode(geom.NodePosition{X: x, Y: y, Z: z})
+
+			name := blocks[i].ResolveName(node.ID)
+			if name == "air" || name == "ignore" {
+				continue
+			}
+
+			nodeDef := r.game.NodeDef(name)
+			if !r.isVisible(&nodeDef) {
+				continue
+			}
+
+			if nodeDef.DrawType.IsLiquid() {
+				if result.liquid == nil {
+					result.present = true
+					result.height = worldY
+					result.liquid = &nodeDef
+					result.liquidTint = nodeDef.PaletteColor(nodeDef.PaletteIndex(node.Param2))
+				}
+
+				continue
+			}
+
+			if result.liquid != nil {
+				result.liquidDepth = result.height - worldY
+			} else {
+				result.height = worldY
+			}
+
+			result.present = true
+			result.surface = &nodeDef
+			result.surfaceTint = nodeDef.PaletteColor(nodeDef.PaletteIndex(node.Param2))
+
+			return result
+		}
+	}
+
+	return result
+}
+
+func (r *FlatRenderer) averageColor(texture *image.NRGBA) color.NRGBA {
+	if c, ok := r.averageColors[texture]; ok {
+		return c
+	}
+
+	// Colors are weighted by their opacity, so that transparent pixels don't
+	// affect the result
+	var red, green, blue, weight float64
+
+	for y := texture.Rect.Min.Y; y < texture.Rect.Max.Y; y++ {
+		for x := texture.Rect.Min.X; x < texture.Rect.Max.X; x++ {
+			c := texture.NRGBAAt(x, y)
+			alpha := float64(c.A) / 255
+
+			red += float64(c.R) * alpha
+			green += float64(c.G) * alpha
+			blue += float64(c.B) * alpha
+			weight += alpha
+		}
+	}
+
+	c := color.NRGBA{A: 255}
+	if weight > 0 {
+		c.R = uint8(red / weight)
+		c.G = uint8(green / weight)
+		c.B = uint8(blue / weight)
+	}
+
+	r.averageColors[texture] = c
+
+	return c
+}
+
+// sampleSurface returns color of the top face texture at given pixel multiplied
+// by the palette color. Pixels that are mostly transparent are replaced with
+// the average texture color.
+func (r *FlatRenderer) sampleSurface(nodeDef *game.NodeDefinition, tint color.NRGBA, x, y int) color.NRGBA {
+	texture := nodeDef.Textures[0]
+
+	c := texture.NRGBAAt(
+		texture.Rect.Min.X+x*texture.Rect.Dx()/r.resolution,
+		texture.Rect.Min.Y+y*texture.Rect.Dy()/r.resolution,
+	)
+
+	if c.A < 128 {
+		c = r.averageColor(texture)
+	}
+
+	return color.NRGBA{
+		R: uint8(int(c.R) * int(tint.R) / 255),
+		G: uint8(int(c.G) * int(tint.G) / 255),
+		B: uint8(int(c.B) * int(tint.B) / 255),
+		A: c.A,
+	}
+}
+
+func blend(lhs, rhs color.NRGBA, alpha float64) color.NRGBA {
+	return color.NRGBA{
+		R: uint8(float64(lhs.R)*(1-alpha) + float64(rhs.R)*alpha),
+		G: uint8(float64(lhs.G)*(1-alpha) + float64(rhs.G)*alpha),
+		B: uint8(float64(lhs.B)*(1-alpha) + float64(rhs.B)*alpha),
+		A: 255,
+	}
+}
+
+func shade(c color.NRGBA, factor float64) color.NRGBA {
+	return color.NRGBA{
+		R: uint8(lm.Clamp(float64(c.R)*factor, 0, 255)),
+		G: uint8(lm.Clamp(float64(c.G)*factor, 0, 255)),
+		B: uint8(lm.Clamp(float64(c.B)*factor, 0, 255)),
+		A: c.A,
+	}
+}
+
+// shadingFactor makes slopes facing north-west brighter and the ones facing
+// south-east darker, and slightly darkens lowlands compared to highlands
+func (r *FlatRenderer) shadingFactor(col column, west, north column) float64 {
+	relief := 0.0
+
+	if west.present {
+		relief += lm.Clamp(float64(col.height-west.height), -maxRelief, maxRelief)
+	}
+
+	if north.present {
+		relief += lm.Clamp(float64(col.height-north.height), -maxRelief, maxRelief)
+	}
+
+	heightRange := float64(r.region.YBounds.Max - r.region.YBounds.Min)
+	altitude := 0.5
+
+	if heightRange > 0 {
+		altitude = float64(col.height-r.region.YBounds.Min) / heightRange
+	}
+
+	return (1 + relief*reliefShading) * (0.85 + 0.3*altitude)
+}
+
+func (r *FlatRenderer) drawColumn(target *rasterizer.RenderBuffer, origin image.Point, col column, factor float64) {
+	for y := 0; y < r.resolution; y++ {
+		for x := 0; x < r.resolution; x++ {
+			var c color.NRGBA
+
+			if col.surface != nil {
+				c = r.sampleSurface(col.surface, col.surfaceTint, x, y)
+			}
+
+			if col.liquid != nil {
+				liquidColor := r.sampleSurface(col.liquid, col.liquidTint, x, y)
+
+				if col.surface == nil {
+					c = liquidColor
+				} else {
+					// Deeper liquid hides more of the bottom
+					alpha := lm.Clamp(0.5+float64(col.liquidDepth)*0.05, 0.5, 0.9)
+					c = blend(c, liquidColor, alpha)
+				}
+			}
+
+			c.A = 255
+
+			target.Color.SetNRGBA(origin.X+x, origin.Y+y, shade(c, factor))
+		}
+	}
+}
+
+func (r *FlatRenderer) RenderTile(
+	tilePos tile.TilePosition,
+	wd *world.World,
+	game *game.Game,
+) *rasterizer.RenderBuffer {
+	rect := image.Rect(0, 0, geom.BlockSize*r.resolution, geom.BlockSize*r.resolution)
+	target := rasterizer.NewRenderBuffer(rect)
+
+	// North is up, so tile Y axis points towards negative Z
+	blockX := tilePos.X
+	blockZ := -tilePos.Y
+
+	columns := r.scanColumns(wd, blockX, blockZ)
+	westColumns := r.scanColumns(wd, blockX-1, blockZ)
+	northColumns := r.scanColumns(wd, blockX, blockZ+1)
+
+	for z := 0; z < geom.BlockSize; z++ {
+		for x := 0; x < geom.BlockSize; x++ {
+			col := columns[z][x]
+			if !col.present {
+				continue
+			}
+
+			west := westColumns[z][geom.BlockSize-1]
+			if x > 0 {
+				west = columns[z][x-1]
+			}
+
+			north := northColumns[0][x]
+			if z < geom.BlockSize-1 {
+				north = columns[z+1][x]
+			}
+
+			origin := image.Point{
+				X: x * r.resolution,
+				Y: (geom.BlockSize - 1 - z) * r.resolution,
+			}
+
+			r.drawColumn(target, origin, col, r.shadingFactor(col, west, north))
+
+			target.Dirty = true
+		}
+	}
+
 	return target
 }
 
 func (r *FlatRenderer) ProjectRegion(region geom.Region) geom.ProjectedRegion {
 	return geom.ProjectedRegion{
 		XBounds: geom.Bounds{
-			Min: region.XBounds.Min / 16,
-			Max: region.XBounds.Max / 16,
+			Min: lm.FloorDiv(region.XBounds.Min, geom.BlockSize),
+			Max: lm.FloorDiv(region.XBounds.Max, geom.BlockSize) + 1,
 		},
 		YBounds: geom.Bounds{
-			Min: region.ZBounds.Min / 16,
-			Max: region.ZBounds.Max / 16,
+			Min: -lm.FloorDiv(region.ZBounds.Max, geom.BlockSize),
+			Max: -lm.FloorDiv(region.ZBounds.Min, geom.BlockSize) + 1,
 		},
 	}
 }
@@ -2,6 +2,7 @@ package isometric
 
 import (
 	"image"
+	"log/slog"
 	"math"
 
 	"github.com/lord-server/panorama/internal/game"
@@ -15,32 +16,67 @@ import (
 	"github.com/lord-server/panorama/pkg/mesh"
 )
 
-var (
-	YOffsetCoef     = int(math.Round(rasterizer.BaseResolution * (1 + math.Sqrt2) / 4))
-	TileBlockWidth  = geom.BlockSize * rasterizer.BaseResolution
-	TileBlockHeight = rasterizer.BaseResolution/2*geom.BlockSize - 1 + YOffsetCoef*geom.BlockSize
-)
-
 type IsometricRenderer struct {
 	nr rasterizer.NodeRasterizer
 
-	region geom.Region
-	game   *game.Game
+	region  geom.Region
+	game    *game.Game
+	options rasterizer.Options
+
+	// Size of a node in pixels and vertical distance between stacked nodes,
+	// both measured before the tile is averaged down, so that nodes are placed
+	// with the precision of supersampling
+	resolution  int
+	yOffsetCoef int
+
+	// Tiles are drawn this many times larger and averaged down afterwards
+	supersampling int
+
+	// Nodes of the current tile drawn after all opaque ones
+	translucent []translucentNode
 }
 
-func NewRenderer(region geom.Region, game *game.Game) *IsometricRenderer {
+// translucentNode is a node which may be seen through, along with its position
+// in the tile
+type translucentNode struct {
+	buffer      *rasterizer.RenderBuffer
+	offset      image.Point
+	depthOffset float64
+	liquid      bool
+}
+
+func NewRenderer(region geom.Region, game *game.Game, options rasterizer.Options) *IsometricRenderer {
+	supersampling := max(options.Supersampling, 1)
+	resolution := options.Resolution * supersampling
+
 	return &IsometricRenderer{
-		nr:     rasterizer.New(lm.DimetricProjection()),
-		region: region,
-		game:   game,
+		nr:      rasterizer.New(lm.DimetricProjection(), resolution, options.Lighting),
+		region:  region,
+		game:    game,
+		options: options,
+
+		resolution:  resolution,
+		yOffsetCoef: int(math.Round(float64(resolution) * (1 + math.Sqrt2) / 4)),
+
+		supersampling: supersampling,
 	}
 }
 
+// nodeLight returns the light a node is lit with
+func (r *IsometricRenderer) nodeLight(param1 uint8, nodeDef *game.NodeDefinition) light.Light {
+	if r.options.Night {
+		return light.Night(param1, nodeDef.LightSource)
+	}
+
+	return light.Day(param1, nodeDef.LightSource)
+}
+
 func (r *IsometricRenderer) renderNode(
 	target *rasterizer.RenderBuffer,
 	pos geom.NodePosition,
 	worldPos geom.NodePosition,
 	neighborhood *nn.BlockNeighborhood,
+	shadows *shadowLookup,
 	offset image.Point,
 	depthOffset float64,
 ) {
@@ -67,16 +103,46 @@ func (r *IsometricRenderer) renderNode(
 	}
 
 	renderableNode := rasterizer.RenderableNode{
-		Name:        name,
-		Light:       light.Decode(maxParam1),
-		Param2:      param2,
-		HiddenFaces: hiddenFaces,
+		Name:         name,
+		Light:        r.nodeLight(maxParam1, &nodeDef),
+		Param2:       param2,
+		PaletteIndex: nodeDef.PaletteIndex(param2),
+		HiddenFaces:  hiddenFaces,
+		Connections:  rasterizer.Connections(r.game, name, &nodeDef, pos, neighborhood),
+		LiquidLevels: rasterizer.LiquidLevels(name, &nodeDef, pos, neighborhood),
+	}
+
+	if r.options.SmoothLighting {
+		renderableNode.CornerLight = r.cornerLight(&nodeDef, neighborhood, pos, worldPos, maxParam1)
+	}
+
+	// The sun doesn't shine at night
+	if r.options.ShadowStrength > 0 && !r.options.Night {
+		r.applyShadows(&renderableNode, shadows, pos, worldPos)
 	}
+
 	renderedNode := r.nr.Render(renderableNode, &nodeDef)
 
 	depthOffset = -float64(pos.Z+pos.X)/math.Sqrt2 - 0.5*(float64(pos.Y)) + depthOffset
+
+	// Plants may be displaced within their positions, which for a parallel
+	// projection amounts to shifting the rendered node
+	plantOffset := rasterizer.PlantlikeOffset(&nodeDef, param2, worldPos)
+	if plantOffset != (lm.Vector3{}) {
+		offset = offset.Add(image.Point{
+			X: int(math.Round(float64(r.resolution) * (plantOffset.Z - plantOffset.X) / 2)),
+			Y: int(math.Round(float64(r.resolution)*(plantOffset.Z+plantOffset.X)/4 - float64(r.yOffsetCoef)*plantOffset.Y)),
+		})
+		depthOffset += -(plantOffset.Z+plantOffset.X)/math.Sqrt2 - 0.5*plantOffset.Y
+	}
+
 	if needsAlphaBlending {
-		target.OverlayDepthAwareWithAlpha(renderedNode, offset, depthOffset)
+		r.translucent = append(r.translucent, translucentNode{
+			buffer:      renderedNode,
+			offset:      offset,
+			depthOffset: depthOffset,
+			liquid:      nodeDef.DrawType.IsLiquid(),
+		})
 	} else {
 		target.OverlayDepthAware(renderedNode, offset, depthOffset)
 	}
@@ -108,9 +174,7 @@ func (r *IsometricRenderer) estimateVisibility(
 		neighborPos := pos.Add(offset)
 		neighborName, param1, _ := neighborhood.GetNode(neighborPos)
 
-		if param1 > maxParam1 {
-			maxParam1 = param1
-		}
+		maxParam1 = light.Max(maxParam1, param1)
 
 		// Compute visibility for stacked liquids
 		if nodeDef.DrawType.IsLiquid() {
@@ -128,15 +192,23 @@ func (r *IsometricRenderer) estimateVisibility(
 
 func (r *IsometricRenderer) renderBlock(
 	target *rasterizer.RenderBuffer,
+	wd *world.World,
 	blockPos geom.BlockPosition,
 	neighborhood *nn.BlockNeighborhood,
 	offset image.Point,
 	depthOffset float64,
 ) {
-	rect := image.Rect(0, 0, TileBlockWidth, TileBlockHeight)
+	width := geom.BlockSize * r.resolution
+	height := r.resolution/2*geom.BlockSize - 1 + r.yOffsetCoef*geom.BlockSize
 
 	// FIXME: nodes must define their origin points
-	originX, originY := rect.Dx()/2-rasterizer.BaseResolution/2, rect.Dy()/2+rasterizer.BaseResolution/4+2
+	originX, originY := width/2-r.resolution/2, height/2+r.resolution/4+r.resolution/8
+
+	shadows := shadowLookup{
+		world:        wd,
+		neighborhood: neighborhood,
+		origin:       blockPos.AddNode(geom.NodePosition{}),
+	}
 
 	for z := geom.BlockSize - 1; z >= 0; z-- {
 		for y := geom.BlockSize - 1; y >= 0; y-- {
@@ -149,11 +221,11 @@ func (r *IsometricRenderer) renderBlock(
 				}
 
 				offset := image.Point{
-					X: originX + rasterizer.BaseResolution*(z-x)/2 + offset.X,
-					Y: originY + rasterizer.BaseResolution*(z+x)/4 + offset.Y - YOffsetCoef*y,
+					X: originX + r.resolution*(z-x)/2 + offset.X,
+					Y: originY + r.resolution*(z+x)/4 + offset.Y - r.yOffsetCoef*y,
 				}
 
-				r.renderNode(target, nodePos, nodeWorldPos, neighborhood, offset, depthOffset)
+				r.renderNode(target, nodePos, nodeWorldPos, neighborhood, &shadows, offset, depthOffset)
 			}
 		}
 	}
@@ -161,12 +233,13 @@ func (r *IsometricRenderer) renderBlock(
 
 func (r *IsometricRenderer) RenderTile(
 	tilePos tile.TilePosition,
-	world *world.World,
+	wd *world.World,
 	game *game.Game,
 ) *rasterizer.RenderBuffer {
 	tilePos.Y *= 2
 
-	rect := image.Rect(0, 0, TileBlockWidth, TileBlockWidth)
+	tileSize := geom.BlockSize * r.resolution
+	rect := image.Rect(0, 0, tileSize, tileSize)
 	target := rasterizer.NewRenderBuffer(rect)
 
 	centerX := tilePos.Y - tilePos.X
@@ -176,6 +249,21 @@ func (r *IsometricRenderer) RenderTile(
 	yMin := int(math.Floor(float64(r.region.YBounds.Min) / float64(geom.BlockSize)))
 	yMax := int(math.Ceil(float64(r.region.YBounds.Max) / float64(geom.BlockSize)))
 
+	// Load every block the tile touches, including neighbors of rendered
+	// blocks, with a request per Y level of the sheared prism instead of
+	// fetching them one by one
+	for i := yMin - 1; i <= yMax; i++ {
+		cuboid := world.BlocksInCuboid{
+			Min: geom.BlockPosition{X: centerX + i - 5, Y: centerY + i, Z: centerZ + i - 5},
+			Max: geom.BlockPosition{X: centerX + i + 5, Y: centerY + i, Z: centerZ + i + 5},
+		}
+
+		err := wd.Prefetch(cuboid)
+		if err != nil {
+			slog.Error("unable to prefetch blocks", "error", err)
+		}
+	}
+
 	for i := yMin; i < yMax; i++ {
 		for z := -3; z <= 3; z++ {
 			for x := -3; x <= 3; x++ {
@@ -186,24 +274,30 @@ func (r *IsometricRenderer) RenderTile(
 				}
 
 				neighborhood := nn.BlockNeighborhood{}
-
-				neighborhood.FetchBlock(world, geom.BlockPosition{X: 0, Y: 0, Z: 0}, blockPos)
-				neighborhood.FetchBlock(world, geom.BlockPosition{X: 1, Y: 0, Z: 0}, blockPos)
-				neighborhood.FetchBlock(world, geom.BlockPosition{X: 0, Y: 1, Z: 0}, blockPos)
-				neighborhood.FetchBlock(world, geom.BlockPosition{X: 0, Y: 0, Z: 1}, blockPos)
+				neighborhood.FetchNeighbors(wd, blockPos)
 
 				offset := image.Point{
-					X: rasterizer.BaseResolution * (z - x) / 2 * geom.BlockSize,
-					Y: (rasterizer.BaseResolution*(z+x+2*i)/4 - i*YOffsetCoef) * geom.BlockSize,
+					X: r.resolution * (z - x) / 2 * geom.BlockSize,
+					Y: (r.resolution*(z+x+2*i)/4 - i*r.yOffsetCoef) * geom.BlockSize,
 				}
 
 				depthOffset := (-float64(z+x+2*i)/math.Sqrt2 - 0.5*float64(i)) * geom.BlockSize
-				r.renderBlock(target, blockPos, &neighborhood, offset, depthOffset)
+				r.renderBlock(target, wd, blockPos, &neighborhood, offset, depthOffset)
 			}
 		}
 	}
 
-	return target
+	// Nodes which may be seen through are drawn once everything behind them
+	// is in place
+	for _, node := range r.translucent {
+		target.OverlayTranslucent(node.buffer, node.offset, node.depthOffset, node.liquid)
+	}
+
+	r.translucent = r.translucent[:0]
+
+	target.ResolveTranslucency()
+
+	return target.Downsample(r.supersampling)
 }
 
 func (r *IsometricRenderer) ProjectRegion(region geom.Region) geom.ProjectedRegion {
@@ -211,9 +305,9 @@ func (r *IsometricRenderer) ProjectRegion(region geom.Region) geom.ProjectedRegi
 	xMax := int(math.Ceil(float64((region.ZBounds.Max - region.XBounds.Min)) / 2 / geom.BlockSize))
 
 	yMin := int(math.Floor((float64(region.ZBounds.Min+region.XBounds.Min+2*region.YBounds.Max)/4 -
-		float64(region.YBounds.Max*YOffsetCoef)/rasterizer.BaseResolution) / geom.BlockSize))
+		float64(region.YBounds.Max*r.yOffsetCoef)/float64(r.resolution)) / geom.BlockSize))
 	yMax := int(math.Ceil((float64(region.ZBounds.Max+region.XBounds.Max+2*region.YBounds.Min)/4 -
-		float64(region.YBounds.Min*YOffsetCoef)/rasterizer.BaseResolution) / geom.BlockSize))
+		float64(region.YBounds.Min*r.yOffsetCoef)/float64(r.resolution)) / geom.BlockSize))
 
 	return geom.ProjectedRegion{
 		XBounds: geom.Bounds{
@@ -0,0 +1,243 @@
+package isometric
+
+import (
+	"math"
+
+	"github.com/lord-server/panorama/internal/game"
+	"github.com/lord-server/panorama/internal/generator/nn"
+	"github.com/lord-server/panorama/internal/generator/rasterizer"
+	"github.com/lord-server/panorama/internal/world"
+	"github.com/lord-server/panorama/pkg/geom"
+	"github.com/lord-server/panorama/pkg/lm"
+)
+
+// Distance by which shadow rays are moved off the surface they start at
+const shadowRayBias = 1e-3
+
+// shadowLookup finds nodes along rays towards the sun. Nodes near the rendered
+// block are taken from its neighborhood, and the rest from the block cache of
+// the world, so shadows don't depend on the tile being rendered.
+type shadowLookup struct {
+	world        *world.World
+	neighborhood *nn.BlockNeighborhood
+
+	// World position of the node at the origin of the neighborhood
+	origin geom.NodePosition
+}
+
+// nodeAt returns name of the node at given world position
+func (s *shadowLookup) nodeAt(pos geom.NodePosition) string {
+	local := geom.NodePosition{X: pos.X - s.origin.X, Y: pos.Y - s.origin.Y, Z: pos.Z - s.origin.Z}
+
+	inNeighborhood := func(value int) bool {
+		return value >= -geom.BlockSize && value < 2*geom.BlockSize
+	}
+
+	if inNeighborhood(local.X) && inNeighborhood(local.Y) && inNeighborhood(local.Z) {
+		name, _, _ := s.neighborhood.GetNode(local)
+		return name
+	}
+
+	blockPos := geom.BlockPosition{
+		X: lm.FloorDiv(pos.X, geom.BlockSize),
+		Y: lm.FloorDiv(pos.Y, geom.BlockSize),
+		Z: lm.FloorDiv(pos.Z, geom.BlockSize),
+	}
+
+	block, err := s.world.GetBlock(blockPos)
+	if err != nil || block == nil {
+		return "ignore"
+	}
+
+	node := block.GetNode(geom.NodePosition{
+		X: pos.X - blockPos.X*geom.BlockSize,
+		Y: pos.Y - blockPos.Y*geom.BlockSize,
+		Z: pos.Z - blockPos.Z*geom.BlockSize,
+	})
+
+	return block.ResolveName(node.ID)
+}
+
+func nodeVector(pos geom.NodePosition) lm.Vector3 {
+	return lm.Vec3(float64(pos.X), float64(pos.Y), float64(pos.Z))
+}
+
+// castsShadow tells whether the node blocks sunlight. Unlike solid nodes,
+// leaves let light through their gaps but still cast shadows.
+func (r *IsometricRenderer) castsShadow(name string) bool {
+	if r.isSolid(name) {
+		return true
+	}
+
+	if name == "air" || name == "ignore" {
+		return false
+	}
+
+	nodeDef := r.game.NodeDef(name)
+
+	return nodeDef.DrawType == game.DrawTypeAllFaces && nodeDef.Model != nil
+}
+
+// isShadowed walks nodes crossed by the ray from given world position towards
+// the sun, and tells whether any of them casts a shadow. Rays leaving the
+// rendered region are lit, since nodes outside of it aren't visible.
+func (r *IsometricRenderer) isShadowed(lookup *shadowLookup, start lm.Vector3) bool {
+	sun := r.options.Lighting.SunDirection
+	direction := [3]float64{sun.X, sun.Y, sun.Z}
+
+	// Nodes span half a node around their positions, so the grid is shifted
+	// to put node boundaries at integer coordinates
+	position := [3]float64{start.X + 0.5, start.Y + 0.5, start.Z + 0.5}
+
+	var (
+		node, step  [3]int
+		next, delta [3]float64
+	)
+
+	for i := range position {
+		node[i] = int(math.Floor(position[i]))
+
+		switch {
+		case direction[i] > 0:
+			step[i] = 1
+			next[i] = (float64(node[i]+1) - position[i]) / direction[i]
+			delta[i] = 1 / direction[i]
+		case direction[i] < 0:
+			step[i] = -1
+			next[i] = (float64(node[i]) - position[i]) / direction[i]
+			delta[i] = -1 / direction[i]
+		default:
+			next[i] = math.Inf(1)
+			delta[i] = math.Inf(1)
+		}
+	}
+
+	maxDistance := float64(r.options.ShadowDistance)
+
+	for {
+		pos := geom.NodePosition{X: node[0], Y: node[1], Z: node[2]}
+
+		if !r.region.Intersects(pos.Region()) {
+			return false
+		}
+
+		if r.castsShadow(lookup.nodeAt(pos)) {
+			return true
+		}
+
+		axis := 0
+		for i := 1; i < len(next); i++ {
+			if next[i] < next[axis] {
+				axis = i
+			}
+		}
+
+		if next[axis] > maxDistance {
+			return false
+		}
+
+		node[axis] += step[axis]
+		next[axis] += delta[axis]
+	}
+}
+
+// applyShadows darkens corners of faces facing the sun which it doesn't reach.
+// Rays start at face corners, so shadow edges are blended across faces the
+// same way as smooth lighting.
+func (r *IsometricRenderer) applyShadows(
+	node *rasterizer.RenderableNode,
+	lookup *shadowLookup,
+	pos geom.NodePosition,
+	worldPos geom.NodePosition,
+) {
+	shadow := 1 - r.options.ShadowStrength
+	sun := r.options.Lighting.SunDirection
+
+	var factors [len(rasterizer.SmoothFaces)][4]float64
+
+	shadowed := false
+
+	for i, face := range rasterizer.SmoothFaces {
+		factors[i] = [4]float64{1, 1, 1, 1}
+
+		normal, u, v := nodeVector(face.Normal), nodeVector(face.U), nodeVector(face.V)
+
+		// Faces turned away from the sun are already darkened by directional
+		// lighting, and faces covered by solid nodes aren't visible at all
+		if normal.Dot(sun) <= 0 {
+			continue
+		}
+
+		if name, _, _ := lookup.neighborhood.GetNode(pos.Add(face.Normal)); r.isSolid(name) {
+			continue
+		}
+
+		for corner := 0; corner < 4; corner++ {
+			signU, signV := rasterizer.CornerSigns(corner)
+
+			start := nodeVector(worldPos).
+				Add(normal.MulScalar(0.5 + shadowRayBias)).
+				Add(u.MulScalar(0.5 * float64(signU))).
+				Add(v.MulScalar(0.5 * float64(signV))).
+				Add(sun.MulScalar(shadowRayBias))
+
+			if r.isShadowed(lookup, start) {
+				factors[i][corner] = shadow
+				shadowed = true
+			}
+		}
+	}
+
+	if !shadowed {
+		return
+	}
+
+	// Faces which aren't lit smoothly get light of the whole node. Shadows only
+	// block light of the sky.
+	if node.CornerLight == (rasterizer.CornerLight{}) {
+		for i := range node.CornerLight {
+			for corner := range node.CornerLight[i] {
+				node.CornerLight[i][corner] = node.Light
+			}
+		}
+	}
+
+	for i := range node.CornerLight {
+		for corner := range node.CornerLight[i] {
+			node.CornerLight[i][corner].Sky = node.CornerLight[i][corner].Sky.MulScalar(factors[i][corner])
+		}
+	}
+
+	// Surfaces at an angle to faces, like plants, are darkened by the shadow
+	// covering the top of the node
+	top := factors[0]
+	node.Light.Sky = node.Light.Sky.MulScalar((top[0] + top[1] + top[2] + top[3]) / 4)
+}
+
+// AffectedRegion extends the region by the length of shadows cast by its nodes
+func (r *IsometricRenderer) AffectedRegion(region geom.Region) geom.Region {
+	if r.options.ShadowStrength <= 0 || r.options.Night {
+		return region
+	}
+
+	// Shadows fall away from the sun
+	extend := func(bounds geom.Bounds, direction float64) geom.Bounds {
+		reach := int(math.Ceil(math.Abs(direction) * float64(r.options.ShadowDistance)))
+
+		if direction < 0 {
+			bounds.Max += reach
+		} else {
+			bounds.Min -= reach
+		}
+
+		return bounds
+	}
+
+	sun := r.options.Lighting.SunDirection
+
+	return geom.Region{
+		XBounds: extend(region.XBounds, sun.X),
+		YBounds: extend(region.YBounds, sun.Y),
+		ZBounds: extend(region.ZBounds, sun.Z),
+	}
+}
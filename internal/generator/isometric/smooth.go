@@ -0,0 +1,97 @@
+package isometric
+
+import (
+	"github.com/lord-server/panorama/internal/game"
+	"github.com/lord-server/panorama/internal/generator/light"
+	"github.com/lord-server/panorama/internal/generator/nn"
+	"github.com/lord-server/panorama/internal/generator/rasterizer"
+	"github.com/lord-server/panorama/pkg/geom"
+)
+
+// Brightness of face corners indexed by the number of solid nodes around them
+var occlusionFactors = [4]float64{1, 0.82, 0.66, 0.5}
+
+// isSolid tells whether the node is drawn as an opaque cube, which blocks
+// light and occludes corners of its neighbors
+func (r *IsometricRenderer) isSolid(name string) bool {
+	if name == "air" || name == "ignore" {
+		return false
+	}
+
+	nodeDef := r.game.NodeDef(name)
+
+	return nodeDef.DrawType == game.DrawTypeNormal && nodeDef.Model != nil
+}
+
+// averageLight averages both light banks of given param1 values
+func averageLight(values []uint8) uint8 {
+	day, night := 0, 0
+
+	for _, value := range values {
+		day += int(value & 0xF)
+		night += int(value >> 4)
+	}
+
+	count := len(values)
+
+	return uint8((day+count/2)/count) | uint8((night+count/2)/count)<<4
+}
+
+// cornerLight computes light at corners of faces facing the viewer, similarly
+// to smooth lighting of the client. Every corner is lit by the nodes in front
+// of the face touching it, and gets darker with every solid one.
+func (r *IsometricRenderer) cornerLight(
+	nodeDef *game.NodeDefinition,
+	neighborhood *nn.BlockNeighborhood,
+	pos geom.NodePosition,
+	worldPos geom.NodePosition,
+	fallback uint8,
+) rasterizer.CornerLight {
+	var result rasterizer.CornerLight
+
+	values := make([]uint8, 0, 4)
+
+	for i, face := range rasterizer.SmoothFaces {
+		front := pos.Add(face.Normal)
+
+		for corner := 0; corner < 4; corner++ {
+			signU, signV := rasterizer.CornerSigns(corner)
+			sideU := front.Add(face.U.Mul(signU))
+			sideV := front.Add(face.V.Mul(signV))
+			diagonal := sideU.Add(face.V.Mul(signV))
+
+			values = values[:0]
+			solid := 0
+
+			for _, sample := range []geom.NodePosition{front, sideU, sideV, diagonal} {
+				// Light doesn't pass through the corner between two solid nodes
+				if sample == diagonal && solid == 2 {
+					solid++
+					break
+				}
+
+				name, param1, _ := neighborhood.GetNode(sample)
+
+				if !r.isSolid(name) {
+					values = append(values, param1)
+				} else if sample != front {
+					solid++
+				}
+			}
+
+			param1 := fallback
+			if len(values) > 0 {
+				param1 = averageLight(values)
+			}
+
+			// Make underground edges visible, same as for the whole node
+			if r.region.IsAtEdge(worldPos) && param1 == light.ZeroIntensity {
+				param1 = light.MapEdgeIntensity
+			}
+
+			result[i][corner] = r.nodeLight(param1, nodeDef).MulScalar(occlusionFactors[min(solid, 3)])
+		}
+	}
+
+	return result
+}
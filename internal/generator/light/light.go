@@ -1,5 +1,7 @@
 package light
 
+import "github.com/lord-server/panorama/pkg/lm"
+
 const (
 	ZeroIntensity    = 0
 	MapEdgeIntensity = 11
@@ -28,3 +30,67 @@ var lut = [16]float64{
 func Decode(param1 uint8) float64 {
 	return lut[param1&0xF]
 }
+
+// Share of sunlight left at midnight, matching the day-night ratio of the
+// client
+const NightRatio = 0.175
+
+var (
+	// MoonColor tints surfaces lit by the sky at night
+	MoonColor = lm.Vec3(0.55, 0.65, 1)
+
+	// WarmColor tints surfaces lit by light sources at night
+	WarmColor = lm.Vec3(1, 0.8, 0.55)
+)
+
+// Light reaching a surface, split by where it comes from: only light of the
+// sky depends on the direction the surface is facing
+type Light struct {
+	Sky   lm.Vector3
+	Block lm.Vector3
+}
+
+func (lhs Light) Add(rhs Light) Light {
+	return Light{
+		Sky:   lhs.Sky.Add(rhs.Sky),
+		Block: lhs.Block.Add(rhs.Block),
+	}
+}
+
+func (lhs Light) MulScalar(rhs float64) Light {
+	return Light{
+		Sky:   lhs.Sky.MulScalar(rhs),
+		Block: lhs.Block.MulScalar(rhs),
+	}
+}
+
+// artificial returns intensity of light coming from light sources. The night
+// bank of param1 holds it, and nodes emitting light glow with their own light.
+func artificial(param1 uint8, lightSource int) float64 {
+	return max(lut[param1>>4], lut[min(max(lightSource, 0), FullIntensity)])
+}
+
+// Day returns light of a node at noon
+func Day(param1 uint8, lightSource int) Light {
+	sky := Decode(param1)
+	block := artificial(param1, lightSource)
+
+	return Light{
+		Sky:   lm.Vec3(sky, sky, sky),
+		Block: lm.Vec3(block, block, block),
+	}
+}
+
+// Night returns light of a node at midnight. The day bank of param1 is
+// dimmed to moonlight, while light sources glow with a warm tint.
+func Night(param1 uint8, lightSource int) Light {
+	return Light{
+		Sky:   MoonColor.MulScalar(Decode(param1) * NightRatio),
+		Block: WarmColor.MulScalar(artificial(param1, lightSource) * (1 - NightRatio)),
+	}
+}
+
+// Max returns param1 with the brightest day and night light of both values
+func Max(a, b uint8) uint8 {
+	return max(a&0xF, b&0xF) | max(a&0xF0, b&0xF0)
+}
@@ -3,6 +3,7 @@ package nn
 import (
 	"github.com/lord-server/panorama/internal/world"
 	"github.com/lord-server/panorama/pkg/geom"
+	"github.com/lord-server/panorama/pkg/lm"
 )
 
 type BlockNeighborhood struct {
@@ -25,50 +26,71 @@ func (b *BlockNeighborhood) FetchBlock(w *world.World, posOffset, worldPos geom.
 	b.SetBlock(neighborhoodCenter.Add(posOffset), block)
 }
 
+// FetchNeighbors fetches the block and all blocks surrounding it, since nodes
+// at block edges may connect to nodes of any neighboring block
+func (b *BlockNeighborhood) FetchNeighbors(w *world.World, worldPos geom.BlockPosition) {
+	for z := -1; z <= 1; z++ {
+		for y := -1; y <= 1; y++ {
+			for x := -1; x <= 1; x++ {
+				b.FetchBlock(w, geom.BlockPosition{X: x, Y: y, Z: z}, worldPos)
+			}
+		}
+	}
+}
+
 func (b *BlockNeighborhood) SetBlock(pos geom.BlockPosition, block *world.MapBlock) {
 	b.blocks[blockIndex(pos)] = block
 }
 
-func (b *BlockNeighborhood) getBlockByNodePos(pos geom.NodePosition) *world.MapBlock {
+// splitNodePos returns position of the block containing given node relative to
+// the neighborhood, and position of the node within the block. Nodes of
+// neighboring blocks have negative coordinates or coordinates past the block
+// size.
+func splitNodePos(pos geom.NodePosition) (geom.BlockPosition, geom.NodePosition) {
 	blockPos := geom.BlockPosition{
-		X: pos.X/geom.BlockSize + neighborhoodCenter.X,
-		Y: pos.Y/geom.BlockSize + neighborhoodCenter.Y,
-		Z: pos.Z/geom.BlockSize + neighborhoodCenter.Z,
+		X: lm.FloorDiv(pos.X, geom.BlockSize),
+		Y: lm.FloorDiv(pos.Y, geom.BlockSize),
+		Z: lm.FloorDiv(pos.Z, geom.BlockSize),
+	}
+
+	nodePos := geom.NodePosition{
+		X: pos.X - blockPos.X*geom.BlockSize,
+		Y: pos.Y - blockPos.Y*geom.BlockSize,
+		Z: pos.Z - blockPos.Z*geom.BlockSize,
+	}
+
+	return blockPos.Add(neighborhoodCenter), nodePos
+}
+
+func (b *BlockNeighborhood) getNode(pos geom.NodePosition) (*world.MapBlock, world.Node) {
+	blockPos, nodePos := splitNodePos(pos)
+
+	if blockPos.X < 0 || blockPos.X > 2 || blockPos.Y < 0 || blockPos.Y > 2 || blockPos.Z < 0 || blockPos.Z > 2 {
+		return nil, world.Node{}
 	}
 
-	return b.blocks[blockIndex(blockPos)]
+	block := b.blocks[blockIndex(blockPos)]
+	if block == nil {
+		return nil, world.Node{}
+	}
+
+	return block, block.GetNode(nodePos)
 }
 
 func (b *BlockNeighborhood) GetNode(pos geom.NodePosition) (string, uint8, uint8) {
-	block := b.getBlockByNodePos(pos)
+	block, node := b.getNode(pos)
 
 	if block == nil {
 		return "ignore", 0, 0
 	}
 
-	node := block.GetNode(geom.NodePosition{
-		X: pos.X % geom.BlockSize,
-		Y: pos.Y % geom.BlockSize,
-		Z: pos.Z % geom.BlockSize,
-	})
-
 	name := block.ResolveName(node.ID)
 
 	return name, node.Param1, node.Param2
 }
 
 func (b *BlockNeighborhood) GetParam1(pos geom.NodePosition) uint8 {
-	block := b.getBlockByNodePos(pos)
-
-	if block == nil {
-		return 0
-	}
-
-	node := block.GetNode(geom.NodePosition{
-		X: pos.X % geom.BlockSize,
-		Y: pos.Y % geom.BlockSize,
-		Z: pos.Z % geom.BlockSize,
-	})
+	_, node := b.getNode(pos)
 
 	return node.Param1
 }
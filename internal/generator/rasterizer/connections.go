@@ -0,0 +1,176 @@
+package rasterizer
+
+import (
+	"github.com/lord-server/panorama/internal/game"
+	"github.com/lord-server/panorama/pkg/geom"
+	"github.com/lord-server/panorama/pkg/mesh"
+)
+
+// NodeGetter provides access to nodes surrounding the rendered one
+type NodeGetter interface {
+	GetNode(pos geom.NodePosition) (string, uint8, uint8)
+}
+
+// Directions in the order of bits of FireNeighbors
+var fireDirections = []geom.NodePosition{
+	{X: 0, Y: 0, Z: 1},
+	{X: 0, Y: 1, Z: 0},
+	{X: 1, Y: 0, Z: 0},
+	{X: 0, Y: 0, Z: -1},
+	{X: 0, Y: -1, Z: 0},
+	{X: -1, Y: 0, Z: 0},
+}
+
+// Directions in the order of rail connection bits
+var railDirections = []geom.NodePosition{
+	{X: 0, Y: 0, Z: 1},
+	{X: 0, Y: 0, Z: -1},
+	{X: -1, Y: 0, Z: 0},
+	{X: 1, Y: 0, Z: 0},
+}
+
+// Rails climbing towards the neighbor above in given direction are rotated by
+// this angle
+var railSlopeAngles = []float64{0, 180, 90, -90}
+
+// Tile and rotation of rails indexed by connection bits
+var railKinds = [16]struct {
+	tile  int
+	angle float64
+}{
+	{0, 0}, {0, 0}, {0, 0}, {0, 0},
+	{0, 90}, {1, 180}, {1, 270}, {2, 180},
+	{0, 90}, {1, 90}, {1, 0}, {2, 0},
+	{0, 90}, {2, 90}, {2, 270}, {3, 0},
+}
+
+// Directions in the order of nodebox connection bits
+var nodeBoxDirections = []geom.NodePosition{
+	{X: 0, Y: 1, Z: 0},
+	{X: 0, Y: -1, Z: 0},
+	{X: 0, Y: 0, Z: -1},
+	{X: -1, Y: 0, Z: 0},
+	{X: 0, Y: 0, Z: 1},
+	{X: 1, Y: 0, Z: 0},
+}
+
+const railConnectionGroup = "connect_to_raillike"
+
+// Connections describes neighbors the node connects to, which change its
+// appearance. Meaning of the bits depends on the drawtype.
+func Connections(g *game.Game, name string, nodeDef *game.NodeDefinition, pos geom.NodePosition, neighbors NodeGetter) uint8 {
+	switch nodeDef.DrawType {
+	case game.DrawTypeFirelike:
+		return fireConnections(name, pos, neighbors)
+	case game.DrawTypeRaillike:
+		return railConnections(g, name, nodeDef, pos, neighbors)
+	case game.DrawTypeFencelike:
+		return fenceConnections(g, pos, neighbors)
+	case game.DrawTypeNodeBox:
+		if nodeDef.NodeBox == nil || nodeDef.NodeBox.Type != "connected" {
+			return 0
+		}
+
+		return nodeBoxConnections(g, name, nodeDef, pos, neighbors)
+	default:
+		return 0
+	}
+}
+
+// fireConnections returns FireNeighbors of the node: flames climb walls of
+// any other nodes
+func fireConnections(name string, pos geom.NodePosition, neighbors NodeGetter) uint8 {
+	var connections mesh.FireNeighbors
+
+	for i, dir := range fireDirections {
+		neighborName, _, _ := neighbors.GetNode(pos.Add(dir))
+		if neighborName != "air" && neighborName != "ignore" && neighborName != name {
+			connections |= 1 << i
+		}
+	}
+
+	return uint8(connections)
+}
+
+// railConnections returns a bit per direction with a rail on the same level,
+// below or above, followed by a bit per direction with a rail above
+func railConnections(g *game.Game, name string, nodeDef *game.NodeDefinition, pos geom.NodePosition, neighbors NodeGetter) uint8 {
+	group := nodeDef.Groups[railConnectionGroup]
+
+	isSameRail := func(pos geom.NodePosition) bool {
+		neighborName, _, _ := neighbors.GetNode(pos)
+		if neighborName == name {
+			return true
+		}
+
+		neighborDef := g.NodeDef(neighborName)
+
+		return neighborDef.DrawType == game.DrawTypeRaillike && neighborDef.Groups[railConnectionGroup] == group
+	}
+
+	var connections uint8
+
+	for i, dir := range railDirections {
+		neighborPos := pos.Add(dir)
+
+		railAbove := isSameRail(neighborPos.Add(geom.NodePosition{Y: 1}))
+		if railAbove {
+			connections |= 1 << (i + len(railDirections))
+		}
+
+		if railAbove || isSameRail(neighborPos) || isSameRail(neighborPos.Add(geom.NodePosition{Y: -1})) {
+			connections |= 1 << i
+		}
+	}
+
+	return connections
+}
+
+// fenceConnections returns a bit per side with another fence
+func fenceConnections(g *game.Game, pos geom.NodePosition, neighbors NodeGetter) uint8 {
+	var connections uint8
+
+	for i, dir := range nodeBoxDirections {
+		if dir.Y != 0 {
+			continue
+		}
+
+		neighborName, _, _ := neighbors.GetNode(pos.Add(dir))
+		if neighborDef := g.NodeDef(neighborName); neighborDef.DrawType == game.DrawTypeFencelike {
+			connections |= 1 << i
+		}
+	}
+
+	return connections
+}
+
+// nodeBoxConnections returns a bit per direction the connected nodebox
+// connects to. Neighbors which are connected nodeboxes themselves must
+// connect back, and other nodes may restrict sides they can be connected to.
+func nodeBoxConnections(g *game.Game, name string, nodeDef *game.NodeDefinition, pos geom.NodePosition, neighbors NodeGetter) uint8 {
+	var connections uint8
+
+	for i, dir := range nodeBoxDirections {
+		neighborName, _, _ := neighbors.GetNode(pos.Add(dir))
+		if !nodeDef.ConnectsTo[neighborName] {
+			continue
+		}
+
+		neighborDef := g.NodeDef(neighborName)
+
+		switch {
+		case neighborDef.NodeBox != nil && neighborDef.NodeBox.Type == "connected":
+			if !neighborDef.ConnectsTo[name] {
+				continue
+			}
+		case neighborDef.ConnectSides != 0:
+			if neighborDef.ConnectSides&(1<<i) == 0 {
+				continue
+			}
+		}
+
+		connections |= 1 << i
+	}
+
+	return connections
+}
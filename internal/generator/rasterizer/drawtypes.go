@@ -0,0 +1,119 @@
+package rasterizer
+
+import (
+	"image"
+
+	"github.com/lord-server/panorama/internal/game"
+	"github.com/lord-server/panorama/pkg/mesh"
+)
+
+func tileTexture(nodeDef *game.NodeDefinition, tile int) []*image.NRGBA {
+	return []*image.NRGBA{nodeDef.Textures[min(tile, len(nodeDef.Textures)-1)]}
+}
+
+func torchlikeMesh(node RenderableNode, nodeDef *game.NodeDefinition) (*mesh.Model, []*image.NRGBA) {
+	wall := nodeDef.WallMounted(node.Param2)
+
+	// Torches have separate tiles for the floor, ceiling and walls
+	tile := 2
+
+	switch wall {
+	case mesh.WallMountedFloor, mesh.WallMountedFloorRotated:
+		tile = 0
+	case mesh.WallMountedCeiling, mesh.WallMountedCeilingRotated:
+		tile = 1
+	}
+
+	return mesh.Torchlike(wall, nodeDef.VisualScale/2), tileTexture(nodeDef, tile)
+}
+
+func signlikeMesh(node RenderableNode, nodeDef *game.NodeDefinition) (*mesh.Model, []*image.NRGBA) {
+	return mesh.Signlike(nodeDef.WallMounted(node.Param2), nodeDef.VisualScale/2), tileTexture(nodeDef, 0)
+}
+
+func firelikeMesh(node RenderableNode, nodeDef *game.NodeDefinition) (*mesh.Model, []*image.NRGBA) {
+	return mesh.Firelike(mesh.FireNeighbors(node.Connections), nodeDef.VisualScale/2), tileTexture(nodeDef, 0)
+}
+
+func raillikeMesh(node RenderableNode, nodeDef *game.NodeDefinition) (*mesh.Model, []*image.NRGBA) {
+	kind := railKinds[node.Connections&0xF]
+
+	// Sloped rails are always straight
+	slopes := node.Connections >> len(railDirections)
+	if slopes == 0 {
+		return mesh.Raillike(kind.angle, false), tileTexture(nodeDef, kind.tile)
+	}
+
+	angle := 0.0
+
+	for i := range railDirections {
+		if slopes&(1<<i) != 0 {
+			angle = railSlopeAngles[i]
+		}
+	}
+
+	return mesh.Raillike(angle, true), tileTexture(nodeDef, 0)
+}
+
+// nodeBoxMesh builds boxes of nodes which depend on param2 or neighbors,
+// falling back to the prebuilt model for fixed nodeboxes
+func nodeBoxMesh(node RenderableNode, nodeDef *game.NodeDefinition) (*mesh.Model, []*image.NRGBA) {
+	if nodeDef.DrawType == game.DrawTypeNodeBox && (nodeDef.NodeBox == nil || !isVariableNodeBox(nodeDef.NodeBox)) {
+		return nodeDef.Model, nodeDef.Textures
+	}
+
+	return game.BoxesModel(nodeDef.Boxes(node.Param2, node.Connections), nodeDef.Tiles)
+}
+
+func isVariableNodeBox(nodeBox *game.NodeBox) bool {
+	switch nodeBox.Type {
+	case "connected", "wallmounted", "leveled":
+		return true
+	default:
+		return false
+	}
+}
+
+// specialTile returns one of special tiles of the node, falling back to its
+// regular tiles
+func specialTile(nodeDef *game.NodeDefinition, tile int) *image.NRGBA {
+	if tile < len(nodeDef.SpecialTiles) {
+		return nodeDef.SpecialTiles[tile]
+	}
+
+	return tileTexture(nodeDef, tile)[0]
+}
+
+// flowingLiquidMesh uses the first special tile for the surface and the
+// second one for sides
+func flowingLiquidMesh(node RenderableNode, nodeDef *game.NodeDefinition) (*mesh.Model, []*image.NRGBA) {
+	textures := []*image.NRGBA{specialTile(nodeDef, 0), specialTile(nodeDef, 1)}
+
+	return mesh.Liquid(node.LiquidLevels, node.HiddenFaces), textures
+}
+
+// glasslikeMesh fills glass nodes with the liquid of their first special tile,
+// up to the level stored in param2
+func glasslikeMesh(node RenderableNode, nodeDef *game.NodeDefinition) (*mesh.Model, []*image.NRGBA) {
+	const (
+		maxLevel = 63
+		inset    = 0.5 - 1.0/16
+	)
+
+	level := node.Param2 & maxLevel
+	if nodeDef.ParamType2 != game.ParamType2GlassLikeLiquidLevel || level == 0 {
+		return nodeDef.Model, nodeDef.Textures
+	}
+
+	top := -inset + 2*inset*float64(level)/maxLevel
+	model := mesh.NewModel()
+	model.Meshes = append(model.Meshes, nodeDef.Model.Meshes...)
+	model.Meshes = append(model.Meshes, mesh.Cuboid(-inset, -inset, -inset, inset, top, inset, mesh.CubeFaceNone)...)
+
+	textures := append([]*image.NRGBA{}, nodeDef.Textures...)
+	for len(textures) < len(model.Meshes) {
+		textures = append(textures, specialTile(nodeDef, 0))
+	}
+
+	return &model, textures
+}
@@ -0,0 +1,55 @@
+package rasterizer
+
+import (
+	"math"
+
+	"github.com/lord-server/panorama/internal/generator/light"
+	"github.com/lord-server/panorama/pkg/lm"
+)
+
+// Lighting describes how surfaces of nodes are shaded
+type Lighting struct {
+	// SunDirection is a unit vector pointing from surfaces towards the sun,
+	// or the moon at night
+	SunDirection lm.Vector3
+	// Ambient is the share of sky light reaching surfaces regardless of the
+	// direction they are facing
+	Ambient float64
+	// Gamma is used to convert texture colors into linear light and back
+	Gamma float64
+}
+
+// shader shades fragments of nodes according to lighting
+type shader struct {
+	Lighting
+
+	// Scale of sky light, which makes the brightest face fully lit
+	skyScale float64
+}
+
+func newShader(lighting Lighting) shader {
+	sun := lighting.SunDirection
+	brightest := math.Max(math.Abs(sun.X), math.Max(math.Abs(sun.Y), math.Abs(sun.Z)))
+
+	return shader{
+		Lighting: lighting,
+		skyScale: 1 / (brightest*(1-lighting.Ambient) + lighting.Ambient),
+	}
+}
+
+// light returns light reaching the surface with given normal. Sky light is
+// made of ambient light and light coming from the sun. Surfaces turned away
+// from the sun are lit as if they were facing it, otherwise sides of nodes
+// would be indistinguishable when the view faces the sun. Light of light
+// sources doesn't depend on the direction.
+func (s *shader) light(nodeLight light.Light, normal lm.Vector3) lm.Vector3 {
+	directional := math.Abs(normal.Dot(s.SunDirection))
+	sky := nodeLight.Sky.MulScalar(lm.Clamp((directional*(1-s.Ambient)+s.Ambient)*s.skyScale, 0, 1))
+
+	return sky.Max(nodeLight.Block).ClampScalar(0, 1)
+}
+
+// shade applies light to the linear color of the fragment
+func (s *shader) shade(albedo lm.Vector3, nodeLight light.Light, normal lm.Vector3) lm.Vector3 {
+	return albedo.PowScalar(s.Gamma).Mul(s.light(nodeLight, normal)).PowScalar(1/s.Gamma).ClampScalar(0, 1)
+}
@@ -0,0 +1,142 @@
+package rasterizer
+
+import (
+	"github.com/lord-server/panorama/internal/game"
+	"github.com/lord-server/panorama/pkg/geom"
+	"github.com/lord-server/panorama/pkg/mesh"
+)
+
+const (
+	liquidLevelTop    = 0.5
+	liquidLevelBottom = -0.5
+
+	// Surface of a liquid spreading into the air is slightly raised above the
+	// bottom of the node
+	liquidLevelAir = liquidLevelBottom + 0.02
+)
+
+// liquidNeighbor describes a node next to a flowing liquid
+type liquidNeighbor struct {
+	name      string
+	level     float64
+	isSame    bool
+	topIsSame bool
+}
+
+// liquid holds names of both forms of a liquid
+type liquid struct {
+	source, flowing string
+	levelRange      int
+}
+
+func newLiquid(name string, nodeDef *game.NodeDefinition) liquid {
+	l := liquid{
+		source:     nodeDef.LiquidSource,
+		flowing:    nodeDef.LiquidFlowing,
+		levelRange: min(max(nodeDef.LiquidRange, 1), game.LiquidLevelMax+1),
+	}
+
+	if l.flowing == "" {
+		l.flowing = name
+	}
+
+	return l
+}
+
+func (l liquid) contains(name string) bool {
+	return name == l.source || name == l.flowing
+}
+
+// neighbor returns the surface level of a neighboring node, if it is the same
+// liquid
+func (l liquid) neighbor(pos geom.NodePosition, neighbors NodeGetter) liquidNeighbor {
+	name, _, param2 := neighbors.GetNode(pos)
+	topName, _, _ := neighbors.GetNode(pos.Add(geom.NodePosition{Y: 1}))
+
+	neighbor := liquidNeighbor{
+		name:      name,
+		level:     liquidLevelBottom,
+		topIsSame: l.contains(topName),
+	}
+
+	switch name {
+	case l.source:
+		neighbor.isSame = true
+		neighbor.level = liquidLevelTop
+	case l.flowing:
+		level := int(param2&game.LiquidLevelMask) - (game.LiquidLevelMax + 1 - l.levelRange)
+
+		neighbor.isSame = true
+		neighbor.level = liquidLevelBottom + (float64(level)+0.5)/float64(l.levelRange)
+	}
+
+	return neighbor
+}
+
+// cornerLevel averages levels of the four nodes sharing the corner. Sources
+// and liquids falling from above raise the corner to the top of the node.
+func (l liquid) cornerLevel(nodes [2][2]liquidNeighbor) float64 {
+	sum := 0.0
+	count := 0
+	airCount := 0
+
+	for _, row := range nodes {
+		for _, node := range row {
+			switch {
+			case node.topIsSame, node.name == l.source:
+				return liquidLevelTop
+			case node.name == l.flowing:
+				sum += node.level
+				count++
+			case node.name == "air":
+				airCount++
+			}
+		}
+	}
+
+	if airCount >= 2 {
+		return liquidLevelAir
+	}
+
+	if count > 0 {
+		return sum / float64(count)
+	}
+
+	return 0
+}
+
+// LiquidLevels returns heights of the surface of a flowing liquid at corners
+// of the node, computed from levels of neighboring nodes of the same liquid
+func LiquidLevels(name string, nodeDef *game.NodeDefinition, pos geom.NodePosition, neighbors NodeGetter) mesh.LiquidCorners {
+	if nodeDef.DrawType != game.DrawTypeFlowingLiquid {
+		return mesh.LiquidCorners{}
+	}
+
+	l := newLiquid(name, nodeDef)
+
+	var nodes [3][3]liquidNeighbor
+
+	for z := -1; z <= 1; z++ {
+		for x := -1; x <= 1; x++ {
+			nodes[z+1][x+1] = l.neighbor(pos.Add(geom.NodePosition{X: x, Z: z}), neighbors)
+		}
+	}
+
+	var corners mesh.LiquidCorners
+
+	for z := 0; z < 2; z++ {
+		for x := 0; x < 2; x++ {
+			if nodes[1][1].topIsSame {
+				corners[z][x] = liquidLevelTop
+				continue
+			}
+
+			corners[z][x] = l.cornerLevel([2][2]liquidNeighbor{
+				{nodes[z][x], nodes[z][x+1]},
+				{nodes[z+1][x], nodes[z+1][x+1]},
+			})
+		}
+	}
+
+	return corners
+}
@@ -0,0 +1,76 @@
+package rasterizer
+
+import (
+	"github.com/lord-server/panorama/internal/game"
+	"github.com/lord-server/panorama/pkg/lm"
+)
+
+// Wallmounted directions expressed as facedir values: ceiling, floor, +X, -X,
+// +Z, -Z, and rotated variants of ceiling and floor
+var wallMountedToFaceDir = [8]uint8{20, 0, 16 + 1, 12 + 3, 8, 4 + 2, 20 + 1, 0 + 1}
+
+// faceDir returns the facedir value which param2 of a node corresponds to
+func faceDir(paramType2 game.ParamType2, param2 uint8) (uint8, bool) {
+	switch paramType2 {
+	case game.ParamType2FaceDir, game.ParamType2ColorFaceDir:
+		facedir := param2 & 0x1F
+		if facedir >= 24 {
+			facedir = 0
+		}
+
+		return facedir, true
+
+	case game.ParamType2FourDir, game.ParamType2ColorFourDir:
+		return param2 & 0x3, true
+
+	case game.ParamType2WallMounted, game.ParamType2ColorWallMounted:
+		return wallMountedToFaceDir[param2&0x7], true
+
+	default:
+		return 0, false
+	}
+}
+
+// degRotation returns the rotation angle around the Y axis in degrees
+func degRotation(paramType2 game.ParamType2, param2 uint8) (float64, bool) {
+	switch paramType2 {
+	case game.ParamType2DegRotate:
+		return float64(param2%240) * 1.5, true
+
+	case game.ParamType2ColorDegRotate:
+		return float64((param2&0x1F)%24) * 15, true
+
+	default:
+		return 0, false
+	}
+}
+
+// nodeOrientation returns a function that rotates model vectors according to
+// param2 of the node, or nil if the node isn't rotated
+func nodeOrientation(nodeDef *game.NodeDefinition, param2 uint8) func(lm.Vector3) lm.Vector3 {
+	// Generated meshes already take mounting direction into account
+	switch nodeDef.DrawType {
+	case game.DrawTypeTorchlike, game.DrawTypeSignlike, game.DrawTypeFirelike, game.DrawTypeRaillike:
+		return nil
+	case game.DrawTypeNodeBox:
+		if nodeDef.NodeBox != nil && (nodeDef.NodeBox.Type == "connected" || nodeDef.NodeBox.Type == "wallmounted") {
+			return nil
+		}
+	}
+
+	if facedir, ok := faceDir(nodeDef.ParamType2, param2); ok && facedir != 0 {
+		return func(v lm.Vector3) lm.Vector3 {
+			return transformToFaceDir(v, facedir)
+		}
+	}
+
+	if angle, ok := degRotation(nodeDef.ParamType2, param2); ok && angle != 0 {
+		radians := lm.Radians(-angle)
+
+		return func(v lm.Vector3) lm.Vector3 {
+			return v.RotateXZ(radians)
+		}
+	}
+
+	return nil
+}
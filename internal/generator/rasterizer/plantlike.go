@@ -0,0 +1,79 @@
+package rasterizer
+
+import (
+	"math"
+
+	"github.com/lord-server/panorama/internal/game"
+	"github.com/lord-server/panorama/pkg/geom"
+	"github.com/lord-server/panorama/pkg/lm"
+	"github.com/lord-server/panorama/pkg/mesh"
+)
+
+// Bits of meshoptions param2
+const (
+	meshOptionsStyleMask     = 0x7
+	meshOptionsRandomOffset  = 0x8
+	meshOptionsScaleSqrt2    = 0x10
+	meshOptionsRandomOffsetY = 0x20
+)
+
+func plantlikeMesh(node RenderableNode, nodeDef *game.NodeDefinition) *mesh.Model {
+	style := mesh.PlantStyleCross
+	scale := nodeDef.VisualScale / 2
+	height := 1.0
+
+	switch nodeDef.ParamType2 {
+	case game.ParamType2MeshOptions:
+		style = mesh.PlantStyle(node.Param2 & meshOptionsStyleMask)
+
+		if node.Param2&meshOptionsScaleSqrt2 != 0 {
+			scale *= math.Sqrt2
+		}
+
+	case game.ParamType2Leveled:
+		height = float64(node.Param2) / 16
+
+	default:
+		return nodeDef.Model
+	}
+
+	return mesh.Plantlike(style, scale, height)
+}
+
+// pseudoRandom reproduces the generator Minetest uses for placing plants, so
+// that they end up in the same spots as in game
+type pseudoRandom struct {
+	next int32
+}
+
+func (r *pseudoRandom) Next() int {
+	r.next = r.next*1103515245 + 12345
+
+	return int(uint32(r.next/65536) % 32768)
+}
+
+// PlantlikeOffset returns the random displacement of a plantlike node within
+// its position, measured in nodes. Minetest displaces every quad vertically
+// on its own, the first quad's displacement is used for the whole node here.
+func PlantlikeOffset(nodeDef *game.NodeDefinition, param2 uint8, pos geom.NodePosition) lm.Vector3 {
+	var offset lm.Vector3
+
+	if nodeDef.DrawType != game.DrawTypePlantlike || nodeDef.ParamType2 != game.ParamType2MeshOptions {
+		return offset
+	}
+
+	x, y, z := int32(pos.X), int32(pos.Y), int32(pos.Z)
+
+	if param2&meshOptionsRandomOffset != 0 {
+		rng := pseudoRandom{next: x<<8 | z | y<<16}
+		offset.X = float64(rng.Next()%16)/16*0.29 - 0.145
+		offset.Z = float64(rng.Next()%16)/16*0.29 - 0.145
+	}
+
+	if param2&meshOptionsRandomOffsetY != 0 {
+		rng := pseudoRandom{next: x<<16 | z<<8 | y<<24}
+		offset.Y = -float64(rng.Next()%16) / 16 * 0.125
+	}
+
+	return offset
+}
@@ -5,32 +5,72 @@ import (
 	"image/color"
 	"math"
 
+	lru "github.com/hashicorp/golang-lru/v2"
+
 	"github.com/lord-server/panorama/internal/game"
+	"github.com/lord-server/panorama/internal/generator/light"
 	"github.com/lord-server/panorama/pkg/lm"
 	"github.com/lord-server/panorama/pkg/mesh"
 )
 
-const Gamma = 2.2
-const BaseResolution = 16
+// Options tweak how renderers draw the world
+type Options struct {
+	// Resolution is the size of a node in pixels, tiles span a block
+	Resolution int
+	// Night renders the world at midnight, lit by the moon and light sources
+	Night bool
+	// SmoothLighting interpolates light across faces and darkens corners
+	// surrounded by solid nodes
+	SmoothLighting bool
+	// Supersampling is the number of samples per pixel along each axis,
+	// values above 1 smooth out jagged edges
+	Supersampling int
+	// Lighting controls shading of surfaces
+	Lighting Lighting
+	// ShadowStrength is how much surfaces the sun doesn't reach are darkened,
+	// 0 disables shadows
+	ShadowStrength float64
+	// ShadowDistance is the maximum length of shadow rays in nodes
+	ShadowDistance int
+}
 
 type RenderableNode struct {
-	Name        string
-	Light       float64
-	Param2      uint8
-	HiddenFaces mesh.CubeFaces
+	Name         string
+	Light        light.Light
+	Param2       uint8
+	PaletteIndex uint8
+	HiddenFaces  mesh.CubeFaces
+	Connections  uint8
+	LiquidLevels mesh.LiquidCorners
+	CornerLight  CornerLight
 }
 
+// nodeCacheSize is the number of rendered nodes kept by a rasterizer. Light of
+// smoothly lit and shadowed nodes varies a lot, so most of them are unique and
+// the cache has to be bounded.
+const nodeCacheSize = 2048
+
 type NodeRasterizer struct {
-	cache map[RenderableNode]*RenderBuffer
+	cache *lru.Cache[RenderableNode, *RenderBuffer]
 
 	projection lm.Matrix3
+	resolution int
+	shader     shader
 }
 
-func New(projection lm.Matrix3) NodeRasterizer {
+// New creates a rasterizer drawing nodes with given number of pixels per node
+func New(projection lm.Matrix3, resolution int, lighting Lighting) NodeRasterizer {
+	cache, err := lru.New[RenderableNode, *RenderBuffer](nodeCacheSize)
+	if err != nil {
+		panic(err)
+	}
+
 	return NodeRasterizer{
-		cache: make(map[RenderableNode]*RenderBuffer),
+		cache: cache,
 
 		projection: projection,
+		resolution: resolution,
+		shader:     newShader(lighting),
 	}
 }
 
@@ -57,9 +97,12 @@ func sampleTriangle(x, y int, a, b, c lm.Vector2) (bool, lm.Vector3) {
 }
 
 func sampleTexture(tex *image.NRGBA, texcoord lm.Vector2) lm.Vector4 {
-	x := int(texcoord.X * float64(tex.Rect.Dx()))
-	y := int(texcoord.Y * float64(tex.Rect.Dy()))
-	c := tex.NRGBAAt(x, y)
+	// Textures repeat outside of the [0, 1] range
+	x := int(math.Floor(texcoord.X * float64(tex.Rect.Dx())))
+	y := int(math.Floor(texcoord.Y * float64(tex.Rect.Dy())))
+	x = (x%tex.Rect.Dx() + tex.Rect.Dx()) % tex.Rect.Dx()
+	y = (y%tex.Rect.Dy() + tex.Rect.Dy()) % tex.Rect.Dy()
+	c := tex.NRGBAAt(tex.Rect.Min.X+x, tex.Rect.Min.Y+y)
 
 	return lm.Vector4{
 		X: float64(c.R) / 255,
@@ -69,33 +112,39 @@ func sampleTexture(tex *image.NRGBA, texcoord lm.Vector2) lm.Vector4 {
 	}
 }
 
-var SunLightDir = lm.Vec3(-0.5, 1, -0.8).Normalize()
-var SunLightIntensity = 0.95 / SunLightDir.MaxComponent()
-
-func shadePixel(lighting float64, texture *image.NRGBA, normal lm.Vector3, texcoord lm.Vector2) color.NRGBA {
-	light := SunLightIntensity * lighting * lm.Clamp(math.Abs(normal.Dot(SunLightDir))*0.8+0.2, 0.0, 1.0)
+func (r *NodeRasterizer) shadePixel(
+	nodeLight light.Light,
+	tint lm.Vector3,
+	texture *image.NRGBA,
+	normal lm.Vector3,
+	texcoord lm.Vector2,
+) color.NRGBA {
+	// Surfaces without a texture are colored by the palette only
+	albedo := lm.Vec4(tint.X, tint.Y, tint.Z, 1)
 
 	if texture != nil {
 		rgba := sampleTexture(texture, texcoord)
-		col := rgba.XYZ().PowScalar(Gamma).MulScalar(lighting).PowScalar(1.0/Gamma).ClampScalar(0.0, 1.0)
+		albedo = lm.Vec4(rgba.X*tint.X, rgba.Y*tint.Y, rgba.Z*tint.Z, rgba.W)
+	}
 
-		return color.NRGBA{
-			R: uint8(255 * col.X),
-			G: uint8(255 * col.Y),
-			B: uint8(255 * col.Z),
-			A: uint8(255 * rgba.W),
-		}
-	} else {
-		return color.NRGBA{
-			R: uint8(255 * light),
-			G: uint8(255 * light),
-			B: uint8(255 * light),
-			A: 255,
-		}
+	col := r.shader.shade(albedo.XYZ(), nodeLight, normal)
+
+	return color.NRGBA{
+		R: uint8(255 * col.X),
+		G: uint8(255 * col.Y),
+		B: uint8(255 * col.Z),
+		A: uint8(255 * albedo.W),
 	}
 }
 
-func (r *NodeRasterizer) drawTriangle(target *RenderBuffer, tex *image.NRGBA, lighting float64, a, b, c mesh.Vertex) {
+func (r *NodeRasterizer) drawTriangle(
+	target *RenderBuffer,
+	tex *image.NRGBA,
+	tint lm.Vector3,
+	lighting [3]light.Light,
+	a, b, c mesh.Vertex,
+) {
+	scale := float64(r.resolution) * math.Sqrt2 / 2
 	origin := lm.Vector2{
 		X: float64(target.Color.Bounds().Dx()) / 2,
 		Y: float64(target.Color.Bounds().Dy()) / 2,
@@ -105,9 +154,11 @@ func (r *NodeRasterizer) drawTriangle(target *RenderBuffer, tex *image.NRGBA, li
 	b.Position = r.projection.MulVec(b.Position)
 	c.Position = r.projection.MulVec(c.Position)
 
-	screenSpaceA := a.Position.XY().Mul(lm.Vec2(1, -1)).MulScalar(BaseResolution * math.Sqrt2 / 2).Add(origin)
-	screenSpaceB := b.Position.XY().Mul(lm.Vec2(1, -1)).MulScalar(BaseResolution * math.Sqrt2 / 2).Add(origin)
-	screenSpaceC := c.Position.XY().Mul(lm.Vec2(1, -1)).MulScalar(BaseResolution * math.Sqrt2 / 2).Add(origin)
+	screenSpaceA := a.Position.XY().Mul(lm.Vec2(1, -1)).MulScalar(scale).Add(origin)
+	screenSpaceB := b.Position.XY().Mul(lm.Vec2(1, -1)).MulScalar(scale).Add(origin)
+	screenSpaceC := c.Position.XY().Mul(lm.Vec2(1, -1)).MulScalar(scale).Add(origin)
+
+	uniformLighting := lighting[0] == lighting[1] && lighting[1] == lighting[2]
 
 	bboxMin := screenSpaceA.Min(screenSpaceB).Min(screenSpaceC)
 	bboxMax := screenSpaceA.Max(screenSpaceB).Max(screenSpaceC)
@@ -130,7 +181,14 @@ func (r *NodeRasterizer) drawTriangle(target *RenderBuffer, tex *image.NRGBA, li
 				Add(b.Texcoord.MulScalar(barycentric.Y)).
 				Add(c.Texcoord.MulScalar(barycentric.Z))
 
-			finalColor := shadePixel(lighting, tex, normal, texcoord)
+			pixelLighting := lighting[0]
+			if !uniformLighting {
+				pixelLighting = lighting[0].MulScalar(barycentric.X).
+					Add(lighting[1].MulScalar(barycentric.Y)).
+					Add(lighting[2].MulScalar(barycentric.Z))
+			}
+
+			finalColor := r.shadePixel(pixelLighting, tint, tex, normal, texcoord)
 
 			if finalColor.A > 10 { // FIXME
 				if pixelDepth > target.Depth.At(x, y) {
@@ -177,12 +235,29 @@ func transformToFaceDir(v lm.Vector3, facedir uint8) lm.Vector3 {
 	return v
 }
 
-func (r *NodeRasterizer) createMesh(node RenderableNode, nodeDef *game.NodeDefinition) *mesh.Model {
-	switch {
-	case nodeDef.DrawType.IsLiquid():
-		return mesh.Cube(node.HiddenFaces)
+// createMesh returns the model of the node along with textures of its meshes
+func (r *NodeRasterizer) createMesh(node RenderableNode, nodeDef *game.NodeDefinition) (*mesh.Model, []*image.NRGBA) {
+	switch nodeDef.DrawType {
+	case game.DrawTypeLiquid:
+		return mesh.Cube(node.HiddenFaces), nodeDef.Textures
+	case game.DrawTypeFlowingLiquid:
+		return flowingLiquidMesh(node, nodeDef)
+	case game.DrawTypeGlasslike, game.DrawTypeGlasslikeFramed:
+		return glasslikeMesh(node, nodeDef)
+	case game.DrawTypePlantlike:
+		return plantlikeMesh(node, nodeDef), nodeDef.Textures
+	case game.DrawTypeTorchlike:
+		return torchlikeMesh(node, nodeDef)
+	case game.DrawTypeSignlike:
+		return signlikeMesh(node, nodeDef)
+	case game.DrawTypeFirelike:
+		return firelikeMesh(node, nodeDef)
+	case game.DrawTypeRaillike:
+		return raillikeMesh(node, nodeDef)
+	case game.DrawTypeFencelike, game.DrawTypeNodeBox:
+		return nodeBoxMesh(node, nodeDef)
 	default:
-		return nodeDef.Model
+		return nodeDef.Model, nodeDef.Textures
 	}
 }
 
@@ -191,14 +266,22 @@ func (r *NodeRasterizer) Render(node RenderableNode, nodeDef *game.NodeDefinitio
 		return nil
 	}
 
-	if target, ok := r.cache[node]; ok {
+	if target, ok := r.cache.Get(node); ok {
 		return target
 	}
 
-	rect := image.Rect(0, 0, BaseResolution, BaseResolution+BaseResolution/8)
+	rect := image.Rect(0, 0, r.resolution, r.resolution+r.resolution/8)
 	target := NewRenderBuffer(rect)
 
-	model := r.createMesh(node, nodeDef)
+	model, textures := r.createMesh(node, nodeDef)
+	orient := nodeOrientation(nodeDef, node.Param2)
+
+	// Hardware coloring multiplies textures by the palette color
+	paletteColor := nodeDef.PaletteColor(node.PaletteIndex)
+	tint := lm.Vec3(float64(paletteColor.R), float64(paletteColor.G), float64(paletteColor.B)).DivScalar(255)
+
+	smooth := node.CornerLight != CornerLight{}
+	lighting := [3]light.Light{node.Light, node.Light, node.Light}
 
 	for j, mesh := range model.Meshes {
 		triangleCount := len(mesh.Vertices) / 3
@@ -208,13 +291,21 @@ func (r *NodeRasterizer) Render(node RenderableNode, nodeDef *game.NodeDefinitio
 			vertexB := mesh.Vertices[i*3+1]
 			vertexC := mesh.Vertices[i*3+2]
 
-			if nodeDef.ParamType2 == game.ParamType2FaceDir {
-				vertexA.Position = transformToFaceDir(vertexA.Position, node.Param2)
-				vertexB.Position = transformToFaceDir(vertexB.Position, node.Param2)
-				vertexC.Position = transformToFaceDir(vertexC.Position, node.Param2)
-				vertexA.Normal = transformToFaceDir(vertexA.Normal, node.Param2)
-				vertexB.Normal = transformToFaceDir(vertexB.Normal, node.Param2)
-				vertexC.Normal = transformToFaceDir(vertexC.Normal, node.Param2)
+			if orient != nil {
+				vertexA.Position = orient(vertexA.Position)
+				vertexB.Position = orient(vertexB.Position)
+				vertexC.Position = orient(vertexC.Position)
+				vertexA.Normal = orient(vertexA.Normal)
+				vertexB.Normal = orient(vertexB.Normal)
+				vertexC.Normal = orient(vertexC.Normal)
+			}
+
+			if smooth {
+				lighting = [3]light.Light{
+					vertexLight(&node, vertexA),
+					vertexLight(&node, vertexB),
+					vertexLight(&node, vertexC),
+				}
 			}
 
 			vertexA.Position.Z = -vertexA.Position.Z
@@ -225,11 +316,11 @@ func (r *NodeRasterizer) Render(node RenderableNode, nodeDef *game.NodeDefinitio
 			vertexB.Position.X = -vertexB.Position.X
 			vertexC.Position.X = -vertexC.Position.X
 
-			r.drawTriangle(target, nodeDef.Textures[j], node.Light, vertexA, vertexB, vertexC)
+			r.drawTriangle(target, textures[j], tint, lighting, vertexA, vertexB, vertexC)
 		}
 	}
 
-	r.cache[node] = target
+	r.cache.Add(node, target)
 
 	return target
 }
@@ -1,16 +1,42 @@
 package rasterizer
 
 import (
+	"cmp"
 	"image"
 	"image/color"
+	"math"
+	"slices"
+
+	"github.com/lord-server/panorama/pkg/lm"
 )
 
 type RenderBuffer struct {
 	Color *image.NRGBA
 	Depth *Depth
 	Dirty bool
+
+	// Translucent pixels waiting to be blended over opaque ones
+	fragments []fragment
 }
 
+// fragment is a translucent pixel of a drawn node
+type fragment struct {
+	offset int
+	depth  float64
+	color  color.NRGBA
+	liquid bool
+}
+
+const (
+	// Liquids are as opaque as their textures when this deep, in nodes, and
+	// get more opaque the deeper they are
+	liquidReferenceDepth = 2.0
+	// Liquids deeper than this, or with nothing behind them, are the darkest
+	liquidMaxDepth = 16.0
+	// How much the deepest liquids are darkened
+	liquidDarkening = 0.4
+)
+
 func NewRenderBuffer(rect image.Rectangle) *RenderBuffer {
 	return &RenderBuffer{
 		Color: image.NewNRGBA(rect),
@@ -19,7 +45,10 @@ func NewRenderBuffer(rect image.Rectangle) *RenderBuffer {
 	}
 }
 
-func (target *RenderBuffer) OverlayDepthAwareWithAlpha(source *RenderBuffer, origin image.Point, depthOffset float64) {
+// OverlayTranslucent draws a node which may be partially transparent. Opaque
+// pixels are drawn right away, while translucent ones are kept aside until
+// ResolveTranslucency, so that nodes behind them can still be drawn later.
+func (target *RenderBuffer) OverlayTranslucent(source *RenderBuffer, origin image.Point, depthOffset float64, liquid bool) {
 	target.Dirty = true
 
 	if source == nil {
@@ -37,30 +66,22 @@ func (target *RenderBuffer) OverlayDepthAwareWithAlpha(source *RenderBuffer, ori
 				continue
 			}
 
-			target.Depth.Set(x, y, sourceZ)
-
 			c := source.Color.NRGBAAt(x-origin.X, y-origin.Y)
-			if c.A == 0 {
+
+			switch c.A {
+			case 0:
 				continue
+			case 255:
+				target.Depth.Set(x, y, sourceZ)
+				target.Color.SetNRGBA(x, y, c)
+			default:
+				target.fragments = append(target.fragments, fragment{
+					offset: target.Depth.Rect.Dx()*y + x,
+					depth:  sourceZ,
+					color:  c,
+					liquid: liquid,
+				})
 			}
-
-			d := target.Color.NRGBAAt(x, y)
-
-			// Blend with alpha
-			sourceA := float64(c.A) / 255
-			targetA := float64(d.A) / 255
-
-			outA := sourceA + targetA*(1-sourceA)
-			outR := (float64(c.R)*sourceA + float64(d.R)*targetA*(1-sourceA)) / outA
-			outG := (float64(c.G)*sourceA + float64(d.G)*targetA*(1-sourceA)) / outA
-			outB := (float64(c.B)*sourceA + float64(d.B)*targetA*(1-sourceA)) / outA
-
-			target.Color.SetNRGBA(x, y, color.NRGBA{
-				R: uint8(outR),
-				G: uint8(outG),
-				B: uint8(outB),
-				A: uint8(outA * 255),
-			})
 		}
 	}
 }
@@ -94,14 +115,26 @@ func (target *RenderBuffer) OverlayDepthAware(source *RenderBuffer, origin image
 				continue
 			}
 
-			target.Depth.Pix[targetPixelOffset] = sourceZ
+			// Transparent pixels don't hide anything behind them, and
+			// translucent ones are blended once everything is drawn
+			alpha := source.Color.Pix[sourcePixelOffset*4+3]
+			if alpha == 0 {
+				continue
+			}
+
+			if alpha < 255 {
+				target.fragments = append(target.fragments, fragment{
+					offset: targetPixelOffset,
+					depth:  sourceZ,
+					color:  source.Color.NRGBAAt(x-origin.X, y-origin.Y),
+				})
 
-			sourcePixelOffset *= 4
-			if source.Color.Pix[sourcePixelOffset+3] == 0 {
-				// TODO: support opacity
 				continue
 			}
 
+			target.Depth.Pix[targetPixelOffset] = sourceZ
+
+			sourcePixelOffset *= 4
 			targetPixelOffset *= 4
 
 			target.Color.Pix[targetPixelOffset+0] = source.Color.Pix[sourcePixelOffset+0]
@@ -111,3 +144,123 @@ func (target *RenderBuffer) OverlayDepthAware(source *RenderBuffer, origin image
 		}
 	}
 }
+
+// ResolveTranslucency blends translucent pixels over opaque ones, from the
+// farthest to the nearest, discarding the ones hidden behind opaque pixels.
+// Liquids are tinted by their depth, which is the distance to whatever is seen
+// through them.
+func (target *RenderBuffer) ResolveTranslucency() {
+	slices.SortFunc(target.fragments, func(lhs, rhs fragment) int {
+		if lhs.offset != rhs.offset {
+			return cmp.Compare(lhs.offset, rhs.offset)
+		}
+
+		return cmp.Compare(rhs.depth, lhs.depth)
+	})
+
+	for start := 0; start < len(target.fragments); {
+		end := start + 1
+		for end < len(target.fragments) && target.fragments[end].offset == target.fragments[start].offset {
+			end++
+		}
+
+		target.blendFragments(target.fragments[start:end])
+
+		start = end
+	}
+
+	target.fragments = nil
+}
+
+// blendFragments blends fragments of a single pixel ordered from the farthest
+func (target *RenderBuffer) blendFragments(fragments []fragment) {
+	offset := fragments[0].offset
+	pix := target.Color.Pix[offset*4 : offset*4+4]
+
+	r, g, b, a := float64(pix[0]), float64(pix[1]), float64(pix[2]), float64(pix[3])/255
+	behind := target.Depth.Pix[offset]
+
+	for _, f := range fragments {
+		if f.depth > behind {
+			continue
+		}
+
+		alpha := float64(f.color.A) / 255
+		shade := 1.0
+
+		if f.liquid {
+			depth := liquidMaxDepth
+			if behind != math.MaxFloat64 {
+				depth = lm.Clamp(behind-f.depth, 0.5, liquidMaxDepth)
+			}
+
+			alpha = 1 - math.Pow(1-alpha, depth/liquidReferenceDepth)
+			shade = 1 - liquidDarkening*depth/liquidMaxDepth
+		}
+
+		outA := alpha + a*(1-alpha)
+		r = (float64(f.color.R)*shade*alpha + r*a*(1-alpha)) / outA
+		g = (float64(f.color.G)*shade*alpha + g*a*(1-alpha)) / outA
+		b = (float64(f.color.B)*shade*alpha + b*a*(1-alpha)) / outA
+		a = outA
+
+		behind = f.depth
+	}
+
+	target.Depth.Pix[offset] = behind
+	target.Color.SetNRGBA(offset%target.Color.Rect.Dx(), offset/target.Color.Rect.Dx(), color.NRGBA{
+		R: uint8(r),
+		G: uint8(g),
+		B: uint8(b),
+		A: uint8(a * 255),
+	})
+}
+
+// Downsample averages every factor×factor block of pixels into one, weighting
+// colors by their alpha. Depth is taken from the nearest pixel of the block
+func (source *RenderBuffer) Downsample(factor int) *RenderBuffer {
+	if factor <= 1 {
+		return source
+	}
+
+	rect := image.Rect(0, 0, source.Color.Rect.Dx()/factor, source.Color.Rect.Dy()/factor)
+	target := NewRenderBuffer(rect)
+	target.Dirty = source.Dirty
+
+	samples := factor * factor
+
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			var r, g, b, a int
+
+			depth := math.MaxFloat64
+
+			for sy := y * factor; sy < (y+1)*factor; sy++ {
+				for sx := x * factor; sx < (x+1)*factor; sx++ {
+					depth = min(depth, source.Depth.At(sx, sy))
+
+					c := source.Color.NRGBAAt(sx, sy)
+					r += int(c.R) * int(c.A)
+					g += int(c.G) * int(c.A)
+					b += int(c.B) * int(c.A)
+					a += int(c.A)
+				}
+			}
+
+			target.Depth.Set(x, y, depth)
+
+			if a == 0 {
+				continue
+			}
+
+			target.Color.SetNRGBA(x, y, color.NRGBA{
+				R: uint8((r + a/2) / a),
+				G: uint8((g + a/2) / a),
+				B: uint8((b + a/2) / a),
+				A: uint8((a + samples/2) / samples),
+			})
+		}
+	}
+
+	return target
+}
@@ -0,0 +1,67 @@
+package rasterizer
+
+import (
+	"github.com/lord-server/panorama/internal/generator/light"
+	"github.com/lord-server/panorama/pkg/geom"
+	"github.com/lord-server/panorama/pkg/lm"
+	"github.com/lord-server/panorama/pkg/mesh"
+)
+
+// SmoothFace describes a face of a node facing the viewer. Corners of the face
+// lie along its U and V axes.
+type SmoothFace struct {
+	Normal geom.NodePosition
+	U, V   geom.NodePosition
+}
+
+// SmoothFaces lists the faces which receive smooth lighting: the top, east
+// and north faces are the only ones visible in the isometric view
+var SmoothFaces = [3]SmoothFace{
+	{Normal: geom.NodePosition{Y: 1}, U: geom.NodePosition{X: 1}, V: geom.NodePosition{Z: 1}},
+	{Normal: geom.NodePosition{X: 1}, U: geom.NodePosition{Z: 1}, V: geom.NodePosition{Y: 1}},
+	{Normal: geom.NodePosition{Z: 1}, U: geom.NodePosition{X: 1}, V: geom.NodePosition{Y: 1}},
+}
+
+// CornerSigns returns directions along U and V axes of the corner of a face
+func CornerSigns(corner int) (int, int) {
+	return (corner&1)*2 - 1, (corner>>1)*2 - 1
+}
+
+// CornerLight holds light at every corner of smoothly lit faces, in the order
+// of SmoothFaces and CornerSigns. Zero value disables smooth lighting.
+type CornerLight [len(SmoothFaces)][4]light.Light
+
+func axis(pos geom.NodePosition) lm.Vector3 {
+	return lm.Vec3(float64(pos.X), float64(pos.Y), float64(pos.Z))
+}
+
+// at interpolates light between corners of the face the surface at given
+// position is facing. Surfaces facing away from the viewer or at an angle to
+// faces, like plants, aren't lit smoothly.
+func (c *CornerLight) at(position, normal lm.Vector3) (light.Light, bool) {
+	for i, face := range SmoothFaces {
+		if normal.Dot(axis(face.Normal)) < 0.9 {
+			continue
+		}
+
+		u := lm.Clamp(position.Dot(axis(face.U))+0.5, 0, 1)
+		v := lm.Clamp(position.Dot(axis(face.V))+0.5, 0, 1)
+
+		bottom := c[i][0].MulScalar(1 - u).Add(c[i][1].MulScalar(u))
+		top := c[i][2].MulScalar(1 - u).Add(c[i][3].MulScalar(u))
+
+		return bottom.MulScalar(1 - v).Add(top.MulScalar(v)), true
+	}
+
+	return light.Light{}, false
+}
+
+// vertexLight returns light of the vertex, falling back to light of the whole
+// node for surfaces which aren't lit smoothly
+func vertexLight(node *RenderableNode, vertex mesh.Vertex) light.Light {
+	if cornerLight, ok := node.CornerLight.at(vertex.Position, vertex.Normal); ok {
+		return cornerLight
+	}
+
+	return node.Light
+}
@@ -1 +1,142 @@
 package generator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lord-server/panorama/internal/game"
+	"github.com/lord-server/panorama/internal/generator/flat"
+	"github.com/lord-server/panorama/internal/generator/isometric"
+	"github.com/lord-server/panorama/internal/generator/rasterizer"
+	"github.com/lord-server/panorama/internal/generator/tile"
+	"github.com/lord-server/panorama/pkg/geom"
+)
+
+// NightLayerSuffix is appended to names of layers rendered at night
+const NightLayerSuffix = "-night"
+
+// LegacyLayer is the layer of tiles which were written directly into the tile
+// storage before maps were split into layers. Only the flat renderer existed
+// back then, drawing nodes 16 pixels wide.
+const LegacyLayer = "flat-16px"
+
+// LayerName returns the name of the tile layer, which is also the name of its
+// directory, e.g. `isometric-night-16px`. Tiles of different resolutions differ
+// in size, so they are kept in separate layers.
+func LayerName(renderer string, options rasterizer.Options) string {
+	if options.Night {
+		renderer += NightLayerSuffix
+	}
+
+	return fmt.Sprintf("%s-%dpx", renderer, options.Resolution)
+}
+
+// LayerTileSize returns the size of tiles of the named layer at the most
+// detailed zoom level. False is returned if the name isn't a name of a layer.
+func LayerTileSize(name string) (int, bool) {
+	separator := strings.LastIndexByte(name, '-')
+	if separator == -1 {
+		return 0, false
+	}
+
+	pixels, ok := strings.CutSuffix(name[separator+1:], "px")
+	if !ok {
+		return 0, false
+	}
+
+	resolution, err := strconv.Atoi(pixels)
+	if err != nil || resolution <= 0 {
+		return 0, false
+	}
+
+	if _, ok := renderers[strings.TrimSuffix(name[:separator], NightLayerSuffix)]; !ok {
+		return 0, false
+	}
+
+	return TileSize(rasterizer.Options{Resolution: resolution}), true
+}
+
+// TileSize returns the size of tiles in pixels at the most detailed zoom level
+func TileSize(options rasterizer.Options) int {
+	return geom.BlockSize * options.Resolution
+}
+
+// Factory creates a new instance of a renderer. Renderers aren't safe for
+// concurrent use, so every worker gets its own instance.
+type Factory func(region geom.Region, game *game.Game, options rasterizer.Options) tile.Renderer
+
+type registration struct {
+	factory Factory
+
+	// Renderers ignoring node lighting can't render night layers
+	night bool
+}
+
+var renderers = map[string]registration{
+	"isometric": {
+		factory: func(region geom.Region, game *game.Game, options rasterizer.Options) tile.Renderer {
+			return isometric.NewRenderer(region, game, options)
+		},
+		night: true,
+	},
+	"flat": {
+		factory: func(region geom.Region, game *game.Game, options rasterizer.Options) tile.Renderer {
+			return flat.NewRenderer(region, game, options)
+		},
+	},
+}
+
+// RendererNames returns sorted names of all available renderers
+func RendererNames() []string {
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// NewRendererFunc returns a function creating renderers registered under
+// given name
+func NewRendererFunc(
+	name string,
+	region geom.Region,
+	game *game.Game,
+	options rasterizer.Options,
+) (tile.CreateRendererFunc, error) {
+	renderer, ok := renderers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown renderer `%s`, available renderers: %v", name, RendererNames())
+	}
+
+	if options.Night && !renderer.night {
+		return nil, fmt.Errorf("renderer `%s` doesn't support night layers", name)
+	}
+
+	// Isometric projection places nodes a quarter of their size apart
+	if options.Resolution < 4 || options.Resolution%4 != 0 {
+		return nil, fmt.Errorf("resolution must be a positive multiple of 4, got %d", options.Resolution)
+	}
+
+	// Normalizing zero sun direction results in NaNs
+	if !(options.Lighting.SunDirection.Length() > 0) {
+		return nil, errors.New("sun direction must not be zero")
+	}
+
+	if options.Lighting.Ambient < 0 || options.Lighting.Ambient > 1 {
+		return nil, fmt.Errorf("ambient light must be between 0 and 1, got %g", options.Lighting.Ambient)
+	}
+
+	if options.Lighting.Gamma <= 0 {
+		return nil, fmt.Errorf("gamma must be positive, got %g", options.Lighting.Gamma)
+	}
+
+	return func() tile.Renderer {
+		return renderer.factory(region, game, options)
+	}, nil
+}
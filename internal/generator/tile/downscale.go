@@ -58,12 +58,12 @@ func uniquePositions(input []TilePosition) []TilePosition {
 
 // downscalePositions produces downscaled images for given zoom level and returns a list of produced tile positions
 func (t *Tiler) downscalePositions(zoom int, positions []TilePosition) []TilePosition {
-	const quadrantSize = 128
+	quadrantSize := t.tileSize / 2
 
 	var nextPositions []TilePosition
 
 	for _, pos := range positions {
-		target := image.NewNRGBA(image.Rect(0, 0, 256, 256))
+		target := image.NewNRGBA(image.Rect(0, 0, t.tileSize, t.tileSize))
 
 		for quadrantY := 0; quadrantY < 2; quadrantY++ {
 			for quadrantX := 0; quadrantX < 2; quadrantX++ {
@@ -72,7 +72,7 @@ func (t *Tiler) downscalePositions(zoom int, positions []TilePosition) []TilePos
 					continue
 				}
 
-				quadrant := resize.Resize(quadrantSize, quadrantSize, source, resize.Lanczos3)
+				quadrant := resize.Resize(uint(quadrantSize), uint(quadrantSize), source, resize.Lanczos3)
 
 				targetX := quadrantX * quadrantSize
 				targetY := quadrantY * quadrantSize
@@ -0,0 +1,162 @@
+package tile
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lord-server/panorama/internal/game"
+	"github.com/lord-server/panorama/internal/world"
+	"github.com/lord-server/panorama/pkg/geom"
+)
+
+const watermarkFileName = "watermark"
+
+// LoadWatermark returns the game time of the last render. Zero is returned if
+// the map has never been rendered.
+func (t *Tiler) LoadWatermark() (uint32, error) {
+	data, err := os.ReadFile(filepath.Join(t.tilesPath, watermarkFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	watermark, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(watermark), nil
+}
+
+// SaveWatermark persists the game time up to which the map is known to be up
+// to date
+func (t *Tiler) SaveWatermark(watermark uint32) error {
+	err := os.MkdirAll(t.tilesPath, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(t.tilesPath, watermarkFileName), []byte(strconv.FormatUint(uint64(watermark), 10)), 0o600)
+}
+
+// AffectedTiles returns zoom level 0 tiles which block at given position
+// projects into. The block is padded by a node in every direction, since
+// changes also affect visibility and lighting of neighboring nodes, and
+// further by renderers reaching distant nodes.
+func (t *Tiler) AffectedTiles(renderer Renderer, pos geom.BlockPosition) []TilePosition {
+	minNode := pos.AddNode(geom.NodePosition{X: -1, Y: -1, Z: -1})
+	maxNode := pos.AddNode(geom.NodePosition{X: geom.BlockSize, Y: geom.BlockSize, Z: geom.BlockSize})
+
+	blockRegion := geom.Region{
+		XBounds: geom.Bounds{Min: minNode.X, Max: maxNode.X},
+		YBounds: geom.Bounds{Min: minNode.Y, Max: maxNode.Y},
+		ZBounds: geom.Bounds{Min: minNode.Z, Max: maxNode.Z},
+	}
+
+	if !t.region.Intersects(blockRegion) {
+		return nil
+	}
+
+	if reaching, ok := renderer.(ReachingRenderer); ok {
+		blockRegion = reaching.AffectedRegion(blockRegion)
+	}
+
+	projectedRegion := renderer.ProjectRegion(blockRegion)
+
+	var positions []TilePosition
+
+	for x := projectedRegion.XBounds.Min; x < projectedRegion.XBounds.Max; x++ {
+		for y := projectedRegion.YBounds.Min; y < projectedRegion.YBounds.Max; y++ {
+			positions = append(positions, TilePosition{X: x, Y: y})
+		}
+	}
+
+	return positions
+}
+
+// IncrementalRender re-renders tiles affected by blocks modified at or after
+// given game time and updates lower zoom levels covering them. It returns the
+// greatest block timestamp encountered, or the watermark itself if nothing has
+// changed.
+func (t *Tiler) IncrementalRender(
+	game *game.Game,
+	wd *world.World,
+	workers int,
+	watermark uint32,
+	createRenderer CreateRendererFunc,
+) (uint32, error) {
+	renderer := createRenderer()
+	latest := watermark
+	affected := make(map[TilePosition]struct{})
+
+	err := wd.GetBlocks(world.BlocksModifiedSince{Timestamp: watermark}, func(pos geom.BlockPosition, block *world.MapBlock) error {
+		if block.Timestamp() > latest {
+			latest = block.Timestamp()
+		}
+
+		for _, tilePos := range t.AffectedTiles(renderer, pos) {
+			affected[tilePos] = struct{}{}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return watermark, err
+	}
+
+	if len(affected) == 0 {
+		slog.Info("map is up to date", "watermark", watermark)
+
+		return latest, nil
+	}
+
+	positions := make([]TilePosition, 0, len(affected))
+	for pos := range affected {
+		positions = append(positions, pos)
+	}
+
+	slog.Info("performing an incremental render", "tiles", len(positions), "watermark", watermark)
+
+	t.RenderPositions(game, wd, workers, positions, createRenderer)
+	t.DownscalePositions(positions)
+
+	return latest, nil
+}
+
+// RenderQueued re-renders tiles from the queue as they arrive until the
+// context is canceled
+func (t *Tiler) RenderQueued(
+	ctx context.Context,
+	game *game.Game,
+	wd *world.World,
+	workers int,
+	queue *RenderQueue,
+	delay time.Duration,
+	createRenderer CreateRendererFunc,
+) {
+	for {
+		positions, ok := queue.Take(ctx, delay)
+		if !ok {
+			return
+		}
+
+		if len(positions) == 0 {
+			continue
+		}
+
+		slog.Info("rendering updated tiles", "tiles", len(positions))
+
+		t.RenderPositions(game, wd, workers, positions, createRenderer)
+		t.DownscalePositions(positions)
+	}
+}
@@ -0,0 +1,83 @@
+package tile
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// isLegacyEntry tells whether the entry of the tile storage belongs to the
+// tile tree written directly into it, before maps were split into layers
+func isLegacyEntry(entry fs.DirEntry) bool {
+	if !entry.IsDir() {
+		return entry.Name() == watermarkFileName
+	}
+
+	// Zoom levels are stored as non-positive numbers
+	_, err := strconv.Atoi(entry.Name())
+
+	return err == nil
+}
+
+// MigrateLegacyTiles moves zoom levels and the watermark stored in the root of
+// the tile storage into the directory of given layer, so maps rendered before
+// layers were introduced keep being served and updated. It returns the number
+// of moved entries and names of entries left in place because the layer
+// already has them, e.g. after an interrupted migration.
+func MigrateLegacyTiles(tilesPath string, layer string) (int, []string, error) {
+	entries, err := os.ReadDir(tilesPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil, nil
+	}
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	legacy := []string{}
+
+	for _, entry := range entries {
+		if isLegacyEntry(entry) {
+			legacy = append(legacy, entry.Name())
+		}
+	}
+
+	if len(legacy) == 0 {
+		return 0, nil, nil
+	}
+
+	layerPath := filepath.Join(tilesPath, layer)
+
+	err = os.MkdirAll(layerPath, os.ModePerm)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	moved := 0
+	conflicts := []string{}
+
+	for _, name := range legacy {
+		target := filepath.Join(layerPath, name)
+
+		_, err = os.Lstat(target)
+		if err == nil {
+			conflicts = append(conflicts, name)
+			continue
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return moved, conflicts, err
+		}
+
+		err = os.Rename(filepath.Join(tilesPath, name), target)
+		if err != nil {
+			return moved, conflicts, err
+		}
+
+		moved++
+	}
+
+	return moved, conflicts, nil
+}
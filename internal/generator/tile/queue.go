@@ -0,0 +1,68 @@
+package tile
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RenderQueue collects tiles waiting to be re-rendered. A tile pushed several
+// times before being taken from the queue is rendered only once.
+type RenderQueue struct {
+	mutex   sync.Mutex
+	pending map[TilePosition]struct{}
+	signal  chan struct{}
+}
+
+func NewRenderQueue() *RenderQueue {
+	return &RenderQueue{
+		pending: make(map[TilePosition]struct{}),
+		signal:  make(chan struct{}, 1),
+	}
+}
+
+func (q *RenderQueue) Push(positions ...TilePosition) {
+	if len(positions) == 0 {
+		return
+	}
+
+	q.mutex.Lock()
+	for _, pos := range positions {
+		q.pending[pos] = struct{}{}
+	}
+	q.mutex.Unlock()
+
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+// Take blocks until the queue is not empty, then waits for the given delay to
+// coalesce bursts of writes and returns all pending tiles. It returns false
+// if the context is canceled.
+func (q *RenderQueue) Take(ctx context.Context, delay time.Duration) ([]TilePosition, bool) {
+	select {
+	case <-q.signal:
+	case <-ctx.Done():
+		return nil, false
+	}
+
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return nil, false
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	positions := make([]TilePosition, 0, len(q.pending))
+	for pos := range q.pending {
+		positions = append(positions, pos)
+	}
+
+	q.pending = make(map[TilePosition]struct{})
+
+	return positions, true
+}
@@ -1,6 +1,7 @@
 package tile
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
 	"log/slog"
@@ -28,16 +29,26 @@ type Renderer interface {
 	ProjectRegion(region geom.Region) geom.ProjectedRegion
 }
 
+// ReachingRenderer is implemented by renderers in which nodes affect the look
+// of distant nodes, e.g. by casting shadows on them
+type ReachingRenderer interface {
+	// AffectedRegion returns the region containing every node whose look
+	// depends on nodes within given region
+	AffectedRegion(region geom.Region) geom.Region
+}
+
 type Tiler struct {
 	region     geom.Region
 	zoomLevels int
+	tileSize   int
 	tilesPath  string
 }
 
-func NewTiler(region geom.Region, zoomLevels int, tilesPath string) Tiler {
+func NewTiler(region geom.Region, zoomLevels int, tileSize int, tilesPath string) Tiler {
 	return Tiler{
 		region:     region,
 		zoomLevels: zoomLevels,
+		tileSize:   tileSize,
 		tilesPath:  tilesPath,
 	}
 }
@@ -49,13 +60,19 @@ func (t *Tiler) tilePath(x, y, zoom int) string {
 func (t *Tiler) worker(wg *sync.WaitGroup, game *game.Game, world *world.World, renderer Renderer, positions <-chan TilePosition) {
 	for position := range positions {
 		output := renderer.RenderTile(position, world, game)
-		// Don't save empty tiles
+		tilePath := t.tilePath(position.X, position.Y, 0)
+
+		// Don't save empty tiles, and remove ones left from the previous
+		// render, e.g. when every block in the tile was removed
 		if !output.Dirty {
+			err := os.Remove(tilePath)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				slog.Error("unable to remove empty tile", "err", err, "path", tilePath)
+			}
+
 			continue
 		}
 
-		tilePath := t.tilePath(position.X, position.Y, 0)
-
 		err := imageutil.SavePNG(output.Color, tilePath)
 		if err != nil {
 			return
@@ -70,32 +87,41 @@ func (t *Tiler) worker(wg *sync.WaitGroup, game *game.Game, world *world.World,
 type CreateRendererFunc func() Renderer
 
 func (t *Tiler) FullRender(game *game.Game, world *world.World, workers int, region geom.Region, createRenderer CreateRendererFunc) {
+	projectedRegion := createRenderer().ProjectRegion(region)
+
+	var positions []TilePosition
+
+	for x := projectedRegion.XBounds.Min; x < projectedRegion.XBounds.Max; x++ {
+		for y := projectedRegion.YBounds.Min; y < projectedRegion.YBounds.Max; y++ {
+			positions = append(positions, TilePosition{X: x, Y: y})
+		}
+	}
+
+	t.RenderPositions(game, world, workers, positions, createRenderer)
+}
+
+// RenderPositions renders and saves zoom level 0 tiles at given positions
+func (t *Tiler) RenderPositions(game *game.Game, world *world.World, workers int, positions []TilePosition, createRenderer CreateRendererFunc) {
 	var wg sync.WaitGroup
 
-	positions := make(chan TilePosition)
-	projectedRegion := geom.ProjectedRegion{}
+	queue := make(chan TilePosition)
 
 	for i := 0; i < workers; i++ {
 		wg.Add(1)
 
-		renderer := createRenderer()
-		projectedRegion = renderer.ProjectRegion(region)
-
-		go t.worker(&wg, game, world, renderer, positions)
+		go t.worker(&wg, game, world, createRenderer(), queue)
 	}
 
-	for x := projectedRegion.XBounds.Min; x < projectedRegion.XBounds.Max; x++ {
-		err := os.MkdirAll(fmt.Sprintf("%v/%v", path.Join(t.tilesPath, "0"), x), os.ModePerm)
+	for _, position := range positions {
+		err := os.MkdirAll(fmt.Sprintf("%v/%v", path.Join(t.tilesPath, "0"), position.X), os.ModePerm)
 		if err != nil {
 			panic(err)
 		}
 
-		for y := projectedRegion.YBounds.Min; y < projectedRegion.YBounds.Max; y++ {
-			positions <- TilePosition{X: x, Y: y}
-		}
+		queue <- position
 	}
 
-	close(positions)
+	close(queue)
 
 	wg.Wait()
 }
@@ -145,6 +171,25 @@ func (t *Tiler) DownscaleTiles() {
 		panic(err)
 	}
 
+	t.downscaleParents(positions)
+}
+
+// DownscalePositions updates lower resolution tiles covering given zoom level 0
+// tiles
+func (t *Tiler) DownscalePositions(positions []TilePosition) {
+	parents := make([]TilePosition, 0, len(positions))
+
+	for _, pos := range positions {
+		parents = append(parents, TilePosition{
+			X: lm.FloorDiv(pos.X, 2),
+			Y: lm.FloorDiv(pos.Y, 2),
+		})
+	}
+
+	t.downscaleParents(parents)
+}
+
+func (t *Tiler) downscaleParents(positions []TilePosition) {
 	positions = uniquePositions(positions)
 
 	for zoom := 1; zoom <= t.zoomLevels; zoom++ {
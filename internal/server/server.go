@@ -1,16 +1,55 @@
 package server
 
 import (
+	"encoding/json"
 	"io/fs"
 	"log/slog"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/lord-server/panorama/internal/config"
+	"github.com/lord-server/panorama/internal/generator"
 )
 
-func Serve(static fs.FS, config *config.Config) {
+type layerResponse struct {
+	Name     string `json:"name"`
+	TileSize int    `json:"tile_size"`
+}
+
+type layersResponse struct {
+	Default string          `json:"default"`
+	Layers  []layerResponse `json:"layers"`
+}
+
+// listLayers returns the rendered map layers. Every renderer writes into its
+// own subdirectory of the tile storage, other entries are ignored.
+func listLayers(tilesPath string) ([]layerResponse, error) {
+	entries, err := os.ReadDir(tilesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	layers := []layerResponse{}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		if tileSize, ok := generator.LayerTileSize(entry.Name()); ok {
+			layers = append(layers, layerResponse{Name: entry.Name(), TileSize: tileSize})
+		}
+	}
+
+	return layers, nil
+}
+
+// Serve serves the map UI and tiles. The default layer is shown first by
+// the UI.
+func Serve(static fs.FS, config *config.Config, defaultLayer string) {
 	router := chi.NewRouter()
 
 	staticRootDir, err := fs.Sub(static, "ui/build")
@@ -19,6 +58,31 @@ func Serve(static fs.FS, config *config.Config) {
 	}
 
 	router.Handle("/*", http.FileServer(http.FS(staticRootDir)))
+	router.Get("/api/layers", func(w http.ResponseWriter, r *http.Request) {
+		layers, err := listLayers(config.System.TilesPath)
+		if err != nil {
+			slog.Error("unable to list map layers", "err", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err = json.NewEncoder(w).Encode(layersResponse{
+			Default: defaultLayer,
+			Layers:  layers,
+		})
+		if err != nil {
+			slog.Error("unable to write response", "err", err)
+		}
+	})
+	// Tiles were served from the root of the tile storage before maps were
+	// split into layers
+	router.Get("/tiles/{zoom:-?[0-9]+}/*", func(w http.ResponseWriter, r *http.Request) {
+		target := "/tiles/" + generator.LegacyLayer + strings.TrimPrefix(r.URL.Path, "/tiles")
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
 	router.Handle("/tiles/*", http.StripPrefix("/tiles", http.FileServer(http.Dir(config.System.TilesPath))))
 
 	httpServer := &http.Server{
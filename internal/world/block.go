@@ -32,6 +32,13 @@ func readU16(r io.Reader) (uint16, error) {
 	return value, err
 }
 
+func readU32(r io.Reader) (uint32, error) {
+	var value uint32
+	err := binary.Read(r, binary.BigEndian, &value)
+
+	return value, err
+}
+
 func readString(r io.Reader) (string, error) {
 	length, err := readU16(r)
 	if err != nil {
@@ -48,9 +55,14 @@ func readString(r io.Reader) (string, error) {
 	return string(buf), nil
 }
 
+// BlockTimestampUndefined is used by Minetest for blocks that have never been
+// saved with a valid timestamp
+const BlockTimestampUndefined = 0xFFFFFFFF
+
 type MapBlock struct {
-	mappings map[uint16]string
-	nodeData []byte
+	mappings  map[uint16]string
+	nodeData  []byte
+	timestamp uint32
 }
 
 type ReaderCounter struct {
@@ -190,9 +202,13 @@ func decodeLegacyBlock(reader *bytes.Reader, version uint8) (*MapBlock, error) {
 		}
 	}
 
-	// - uint32 timestamp
+	timestamp, err := readU32(reader)
+	if err != nil {
+		return nil, err
+	}
+
 	// - uint8 mappingVersion
-	_, err = reader.Seek(4+1, io.SeekCurrent)
+	_, err = reader.Seek(1, io.SeekCurrent)
 	if err != nil {
 		return nil, err
 	}
@@ -203,8 +219,9 @@ func decodeLegacyBlock(reader *bytes.Reader, version uint8) (*MapBlock, error) {
 	}
 
 	return &MapBlock{
-		mappings: mappings,
-		nodeData: nodeData,
+		mappings:  mappings,
+		nodeData:  nodeData,
+		timestamp: timestamp,
 	}, nil
 }
 
@@ -225,9 +242,18 @@ func decodeBlock(reader *bytes.Reader) (*MapBlock, error) {
 	// Skip:
 	// - uint8 flags
 	// - uint16 lighting_complete
-	// - uint32 timestamp
-	// - uint8 mapping version
-	_, err = reader.Seek(1+2+4+1, io.SeekCurrent)
+	_, err = reader.Seek(1+2, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := readU32(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip uint8 mapping version
+	_, err = reader.Seek(1, io.SeekCurrent)
 	if err != nil {
 		return nil, err
 	}
@@ -251,8 +277,9 @@ func decodeBlock(reader *bytes.Reader) (*MapBlock, error) {
 	}
 
 	return &MapBlock{
-		mappings: mappings,
-		nodeData: nodeData,
+		mappings:  mappings,
+		nodeData:  nodeData,
+		timestamp: timestamp,
 	}, nil
 }
 
@@ -280,6 +307,11 @@ func (b *MapBlock) ResolveName(id uint16) string {
 	return b.mappings[id]
 }
 
+// Timestamp returns game time of the last block modification
+func (b *MapBlock) Timestamp() uint32 {
+	return b.timestamp
+}
+
 func (b *MapBlock) GetNode(pos geom.NodePosition) Node {
 	index := pos.Z*geom.BlockSize*geom.BlockSize + pos.Y*geom.BlockSize + pos.X
 
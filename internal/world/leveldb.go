@@ -0,0 +1,137 @@
+package world
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/lord-server/panorama/pkg/geom"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// levelDBReader is the part of *leveldb.DB used by the backend
+type levelDBReader interface {
+	Get(key []byte, ro *opt.ReadOptions) ([]byte, error)
+	NewIterator(slice *util.Range, ro *opt.ReadOptions) iterator.Iterator
+	Close() error
+}
+
+type LevelDBBackend struct {
+	db levelDBReader
+}
+
+func NewLevelDBBackend(path string) (*LevelDBBackend, error) {
+	db, err := leveldb.OpenFile(path, &opt.Options{
+		ReadOnly:       true,
+		ErrorIfMissing: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &LevelDBBackend{
+		db: db,
+	}, nil
+}
+
+func (l *LevelDBBackend) Close() {
+	l.db.Close()
+}
+
+// Minetest stores packed block positions as decimal strings
+func levelDBKey(pos geom.BlockPosition) []byte {
+	return []byte(strconv.FormatInt(EncodeBlockPosition(pos), 10))
+}
+
+func (l *LevelDBBackend) GetBlockData(pos geom.BlockPosition) ([]byte, error) {
+	data, err := l.db.Get(levelDBKey(pos), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (l *LevelDBBackend) GetBlocks(selector BlockSelector, callback func(geom.BlockPosition, []byte) error) error {
+	visit := func(pos geom.BlockPosition) error {
+		return l.visitBlock(pos, callback)
+	}
+
+	// Keys are not ordered numerically, and keys of a selection are mixed with
+	// longer keys sharing their digits, so small selections with known
+	// positions are probed block by block
+	switch selector := selector.(type) {
+	case BlocksAlongY:
+		for y := selector.MinY; y <= selector.MaxY; y++ {
+			err := visit(geom.BlockPosition{X: selector.X, Y: y, Z: selector.Z})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+
+	case BlocksInCuboid:
+		return selector.ForEach(visit)
+
+	case BlocksAtPositions:
+		for _, pos := range selector.Positions {
+			err := visit(pos)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+
+	default:
+		return l.scanBlocks(selector, callback)
+	}
+}
+
+func (l *LevelDBBackend) scanBlocks(selector BlockSelector, callback func(geom.BlockPosition, []byte) error) error {
+	iter := l.db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		packedPos, err := strconv.ParseInt(string(iter.Key()), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		pos := DecodeBlockPosition(packedPos)
+		if !selector.Contains(pos) {
+			continue
+		}
+
+		// Iterator reuses its buffers, so the value has to be copied
+		data := make([]byte, len(iter.Value()))
+		copy(data, iter.Value())
+
+		err = callback(pos, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return iter.Error()
+}
+
+func (l *LevelDBBackend) visitBlock(pos geom.BlockPosition, callback func(geom.BlockPosition, []byte) error) error {
+	data, err := l.GetBlockData(pos)
+	if err != nil {
+		return err
+	}
+
+	if data == nil {
+		return nil
+	}
+
+	return callback(pos, data)
+}
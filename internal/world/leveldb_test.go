@@ -0,0 +1,109 @@
+package world
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/lord-server/panorama/pkg/geom"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// countingReader counts keys read from the database
+type countingReader struct {
+	levelDBReader
+	reads int
+}
+
+func (c *countingReader) Get(key []byte, ro *opt.ReadOptions) ([]byte, error) {
+	c.reads++
+
+	return c.levelDBReader.Get(key, ro)
+}
+
+func (c *countingReader) NewIterator(slice *util.Range, ro *opt.ReadOptions) iterator.Iterator {
+	return &countingIterator{Iterator: c.levelDBReader.NewIterator(slice, ro), reads: &c.reads}
+}
+
+type countingIterator struct {
+	iterator.Iterator
+	reads *int
+}
+
+func (c *countingIterator) Next() bool {
+	*c.reads++
+
+	return c.Iterator.Next()
+}
+
+// newDenseLevelDBBackend returns a backend storing every block within given
+// distance of the origin, each holding its own key as data
+func newDenseLevelDBBackend(t *testing.T, radius int) (*LevelDBBackend, *countingReader) {
+	db, err := leveldb.OpenFile(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch := new(leveldb.Batch)
+
+	for z := -radius; z <= radius; z++ {
+		for y := -radius; y <= radius; y++ {
+			for x := -radius; x <= radius; x++ {
+				key := levelDBKey(geom.BlockPosition{X: x, Y: y, Z: z})
+				batch.Put(key, key)
+			}
+		}
+	}
+
+	err = db.Write(batch, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := &countingReader{levelDBReader: db}
+	backend := &LevelDBBackend{db: reader}
+
+	t.Cleanup(backend.Close)
+
+	return backend, reader
+}
+
+func TestLevelDBBlocksAlongY(t *testing.T) {
+	backend, reader := newDenseLevelDBBackend(t, 12)
+
+	selector := BlocksAlongY{X: 0, Z: 0, MinY: -4, MaxY: 20}
+
+	var ys []int
+
+	err := backend.GetBlocks(selector, func(pos geom.BlockPosition, data []byte) error {
+		if pos.X != selector.X || pos.Z != selector.Z {
+			t.Errorf("block %v is outside of the column", pos)
+		}
+
+		if string(data) != string(levelDBKey(pos)) {
+			t.Errorf("block %v has data %q", pos, data)
+		}
+
+		ys = append(ys, pos.Y)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []int{}
+	for y := selector.MinY; y <= 12; y++ {
+		want = append(want, y)
+	}
+
+	if !slices.Equal(ys, want) {
+		t.Errorf("ys = %v, want %v", ys, want)
+	}
+
+	if want := selector.MaxY - selector.MinY + 1; reader.reads != want {
+		t.Errorf("read %d keys, want %d", reader.reads, want)
+	}
+}
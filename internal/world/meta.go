@@ -2,8 +2,11 @@ package world
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -39,3 +42,24 @@ func ParseMeta(path string) (Meta, error) {
 
 	return meta, nil
 }
+
+// ReadGameTime returns the game time saved in env_meta.txt of the world at
+// given path
+func ReadGameTime(path string) (uint32, error) {
+	meta, err := ParseMeta(filepath.Join(path, "env_meta.txt"))
+	if err != nil {
+		return 0, err
+	}
+
+	gameTime, ok := meta["game_time"]
+	if !ok {
+		return 0, errors.New("game time not specified")
+	}
+
+	value, err := strconv.ParseUint(gameTime, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid game time: %w", err)
+	}
+
+	return uint32(value), nil
+}
@@ -0,0 +1,40 @@
+package world
+
+import "github.com/lord-server/panorama/pkg/geom"
+
+// Block positions in SQLite and LevelDB backends are packed into a single
+// signed 64-bit integer, with 12 bits allocated for each coordinate.
+const (
+	packedAxisSize     = 4096
+	packedAxisPositive = packedAxisSize / 2
+)
+
+func unpackAxis(i int64) (int, int64) {
+	value := i % packedAxisSize
+	if value < 0 {
+		value += packedAxisSize
+	}
+
+	if value >= packedAxisPositive {
+		value -= packedAxisSize
+	}
+
+	return int(value), (i - value) / packedAxisSize
+}
+
+// EncodeBlockPosition packs block position into an integer the same way
+// Minetest does
+func EncodeBlockPosition(pos geom.BlockPosition) int64 {
+	return int64(pos.Z)*packedAxisSize*packedAxisSize + int64(pos.Y)*packedAxisSize + int64(pos.X)
+}
+
+// DecodeBlockPosition unpacks block position encoded with EncodeBlockPosition
+func DecodeBlockPosition(i int64) geom.BlockPosition {
+	var pos geom.BlockPosition
+
+	pos.X, i = unpackAxis(i)
+	pos.Y, i = unpackAxis(i)
+	pos.Z, _ = unpackAxis(i)
+
+	return pos
+}
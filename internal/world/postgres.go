@@ -0,0 +1,233 @@
+package world
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/lord-server/panorama/pkg/geom"
+)
+
+type PostgresBackend struct {
+	conn *pgxpool.Pool
+}
+
+func NewPostgresBackend(dsn string) (*PostgresBackend, error) {
+	conn, err := pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PostgresBackend{
+		conn: conn,
+	}, nil
+}
+
+func (p *PostgresBackend) Close() {
+	p.conn.Close()
+}
+
+func (p *PostgresBackend) GetBlockData(pos geom.BlockPosition) ([]byte, error) {
+	var data []byte
+
+	err := p.conn.QueryRow(context.Background(), "SELECT data FROM blocks WHERE posx=$1 and posy=$2 and posz=$3", pos.X, pos.Y, pos.Z).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+const postgresSelectBlocks = "SELECT posx, posy, posz, data FROM blocks"
+
+// postgresQuery translates a block selector into an SQL query. Unknown
+// selectors are translated into a full scan, so the results need to be
+// filtered afterwards.
+func postgresQuery(selector BlockSelector) (string, []any, bool) {
+	switch selector := selector.(type) {
+	case BlocksAlongY:
+		sql := postgresSelectBlocks + " WHERE posx=$1 and posz=$2 AND posy BETWEEN $3 AND $4 ORDER BY posy"
+
+		return sql, []any{selector.X, selector.Z, selector.MinY, selector.MaxY}, true
+
+	case BlocksInCuboid:
+		sql := postgresSelectBlocks + " WHERE posx BETWEEN $1 AND $2 AND posy BETWEEN $3 AND $4 AND posz BETWEEN $5 AND $6"
+
+		return sql, []any{
+			selector.Min.X, selector.Max.X,
+			selector.Min.Y, selector.Max.Y,
+			selector.Min.Z, selector.Max.Z,
+		}, true
+
+	case BlocksAtPositions:
+		xs := make([]int32, len(selector.Positions))
+		ys := make([]int32, len(selector.Positions))
+		zs := make([]int32, len(selector.Positions))
+
+		for i, pos := range selector.Positions {
+			xs[i], ys[i], zs[i] = int32(pos.X), int32(pos.Y), int32(pos.Z)
+		}
+
+		sql := postgresSelectBlocks + " JOIN unnest($1::int[], $2::int[], $3::int[]) AS p(x, y, z) " +
+			"ON posx = p.x AND posy = p.y AND posz = p.z"
+
+		return sql, []any{xs, ys, zs}, true
+
+	default:
+		return postgresSelectBlocks, nil, false
+	}
+}
+
+func (p *PostgresBackend) GetBlocks(selector BlockSelector, callback func(geom.BlockPosition, []byte) error) error {
+	sql, args, exact := postgresQuery(selector)
+
+	rows, err := p.conn.Query(context.Background(), sql, args...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			pos  geom.BlockPosition
+			data []byte
+		)
+
+		err = rows.Scan(&pos.X, &pos.Y, &pos.Z, &data)
+		if err != nil {
+			return err
+		}
+
+		if !exact && !selector.Contains(pos) {
+			continue
+		}
+
+		err = callback(pos, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Changes are delivered with NOTIFY on this channel, with comma-separated block
+// position as a payload
+const postgresNotifyChannel = "panorama_blocks"
+
+const postgresNotifyFunction = `
+CREATE OR REPLACE FUNCTION panorama_notify_block() RETURNS trigger AS $$
+BEGIN
+	PERFORM pg_notify('` + postgresNotifyChannel + `', NEW.posx || ',' || NEW.posy || ',' || NEW.posz);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql`
+
+const postgresNotifyTrigger = `
+CREATE TRIGGER panorama_notify_block AFTER INSERT OR UPDATE ON blocks
+FOR EACH ROW EXECUTE FUNCTION panorama_notify_block()`
+
+// InstallChangeTrigger creates a trigger that notifies listeners about
+// modified blocks, unless it already exists
+func (p *PostgresBackend) InstallChangeTrigger(ctx context.Context) error {
+	var exists bool
+
+	err := p.conn.QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'panorama_notify_block' AND tgrelid = 'blocks'::regclass)",
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return nil
+	}
+
+	_, err = p.conn.Exec(ctx, postgresNotifyFunction)
+	if err != nil {
+		return fmt.Errorf("unable to create trigger function: %w", err)
+	}
+
+	_, err = p.conn.Exec(ctx, postgresNotifyTrigger)
+	if err != nil {
+		return fmt.Errorf("unable to create trigger: %w", err)
+	}
+
+	return nil
+}
+
+func parseNotifyPayload(payload string) (geom.BlockPosition, error) {
+	var pos geom.BlockPosition
+
+	parts := strings.Split(payload, ",")
+	if len(parts) != 3 {
+		return pos, fmt.Errorf("invalid block position: `%s`", payload)
+	}
+
+	coords := []*int{&pos.X, &pos.Y, &pos.Z}
+
+	for i, part := range parts {
+		value, err := strconv.Atoi(part)
+		if err != nil {
+			return pos, fmt.Errorf("invalid block position: `%s`", payload)
+		}
+
+		*coords[i] = value
+	}
+
+	return pos, nil
+}
+
+// ListenChanges blocks until the context is canceled or the connection fails,
+// calling the callback for every block saved to the database
+func (p *PostgresBackend) ListenChanges(ctx context.Context, callback func(geom.BlockPosition)) error {
+	// Listening connection is never returned to the pool, since it would keep
+	// receiving notifications
+	conn, err := pgx.ConnectConfig(ctx, p.conn.Config().ConnConfig)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, "LISTEN "+postgresNotifyChannel)
+	if err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		pos, err := parseNotifyPayload(notification.Payload)
+		if err != nil {
+			slog.Warn("skipped notification", "error", err)
+
+			continue
+		}
+
+		callback(pos)
+	}
+}
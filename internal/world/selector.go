@@ -1,15 +1,90 @@
 package world
 
+import "github.com/lord-server/panorama/pkg/geom"
+
+// BlockSelector describes which blocks should be fetched from the world.
+// Selectors don't know anything about the storage, it's up to each Backend to
+// translate them into queries or scans. Backends that don't recognize a
+// selector fall back to scanning all blocks and filtering them with Contains.
 type BlockSelector interface {
-	Query() (string, []any)
+	// Contains reports whether a block at given position may belong to the
+	// selection
+	Contains(pos geom.BlockPosition) bool
 }
 
+// BlocksAlongY selects a column of blocks sharing X and Z coordinates, ordered
+// by Y. Both MinY and MaxY are inclusive.
 type BlocksAlongY struct {
-	X, Z int
+	X, Z       int
+	MinY, MaxY int
+}
+
+func (s BlocksAlongY) Contains(pos geom.BlockPosition) bool {
+	return pos.X == s.X && pos.Z == s.Z && s.MinY <= pos.Y && pos.Y <= s.MaxY
+}
+
+// BlocksInCuboid selects all blocks inside of a cuboid. Both Min and Max are
+// inclusive.
+type BlocksInCuboid struct {
+	Min, Max geom.BlockPosition
+}
+
+func (s BlocksInCuboid) Contains(pos geom.BlockPosition) bool {
+	return s.Min.X <= pos.X && pos.X <= s.Max.X &&
+		s.Min.Y <= pos.Y && pos.Y <= s.Max.Y &&
+		s.Min.Z <= pos.Z && pos.Z <= s.Max.Z
+}
+
+// Volume returns the number of block positions inside of the cuboid
+func (s BlocksInCuboid) Volume() int {
+	if s.Max.X < s.Min.X || s.Max.Y < s.Min.Y || s.Max.Z < s.Min.Z {
+		return 0
+	}
+
+	return (s.Max.X - s.Min.X + 1) * (s.Max.Y - s.Min.Y + 1) * (s.Max.Z - s.Min.Z + 1)
 }
 
-func (s BlocksAlongY) Query() (string, []any) {
-	return "SELECT posx, posy, posz, data FROM blocks WHERE posx=$1 and posz=$2 ORDER BY posy", []any{
-		s.X, s.Z,
+// ForEach calls fn for every block position inside of the cuboid
+func (s BlocksInCuboid) ForEach(fn func(geom.BlockPosition) error) error {
+	for z := s.Min.Z; z <= s.Max.Z; z++ {
+		for y := s.Min.Y; y <= s.Max.Y; y++ {
+			for x := s.Min.X; x <= s.Max.X; x++ {
+				err := fn(geom.BlockPosition{X: x, Y: y, Z: z})
+				if err != nil {
+					return err
+				}
+			}
+		}
 	}
+
+	return nil
+}
+
+// BlocksAtPositions selects blocks from an explicit list of positions
+type BlocksAtPositions struct {
+	Positions []geom.BlockPosition
+}
+
+func (s BlocksAtPositions) Contains(pos geom.BlockPosition) bool {
+	for _, candidate := range s.Positions {
+		if candidate == pos {
+			return true
+		}
+	}
+
+	return false
+}
+
+// BlocksModifiedSince selects blocks whose timestamp is greater or equal to
+// Timestamp, so that blocks saved in the same second as a previous scan aren't
+// missed. Block timestamps are measured in game time (seconds since world
+// creation) and are stored inside of the block data, so backends can't filter
+// them and this selector always results in a full scan. Blocks with an undefined
+// timestamp are never selected.
+type BlocksModifiedSince struct {
+	Timestamp uint32
+}
+
+func (s BlocksModifiedSince) Contains(pos geom.BlockPosition) bool {
+	return true
 }
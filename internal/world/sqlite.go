@@ -0,0 +1,157 @@
+package world
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lord-server/panorama/pkg/geom"
+	_ "modernc.org/sqlite"
+)
+
+type SQLiteBackend struct {
+	db *sql.DB
+}
+
+func NewSQLiteBackend(path string) (*SQLiteBackend, error) {
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%v?mode=ro", path))
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteBackend{
+		db: db,
+	}, nil
+}
+
+func (s *SQLiteBackend) Close() {
+	s.db.Close()
+}
+
+func (s *SQLiteBackend) GetBlockData(pos geom.BlockPosition) ([]byte, error) {
+	var data []byte
+
+	err := s.db.QueryRow("SELECT data FROM blocks WHERE pos=?", EncodeBlockPosition(pos)).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *SQLiteBackend) GetBlocks(selector BlockSelector, callback func(geom.BlockPosition, []byte) error) error {
+	switch selector := selector.(type) {
+	case BlocksAlongY:
+		// Blocks sharing X and Z coordinates differ by a multiple of 4096, and
+		// are ordered by Y within the range below
+		minPos := EncodeBlockPosition(geom.BlockPosition{X: selector.X, Y: selector.MinY, Z: selector.Z})
+		maxPos := EncodeBlockPosition(geom.BlockPosition{X: selector.X, Y: selector.MaxY, Z: selector.Z})
+
+		return s.queryBlocks(
+			"SELECT pos, data FROM blocks WHERE pos BETWEEN ? AND ? AND (pos - ?) % 4096 = 0 ORDER BY pos",
+			selector, callback,
+			minPos, maxPos, selector.X)
+
+	case BlocksInCuboid:
+		// Rows of blocks along X occupy contiguous ranges of packed positions,
+		// which are all passed to a single query
+		ranges := make([][2]int64, 0, (selector.Max.Z-selector.Min.Z+1)*(selector.Max.Y-selector.Min.Y+1))
+
+		for z := selector.Min.Z; z <= selector.Max.Z; z++ {
+			for y := selector.Min.Y; y <= selector.Max.Y; y++ {
+				ranges = append(ranges, [2]int64{
+					EncodeBlockPosition(geom.BlockPosition{X: selector.Min.X, Y: y, Z: z}),
+					EncodeBlockPosition(geom.BlockPosition{X: selector.Max.X, Y: y, Z: z}),
+				})
+			}
+		}
+
+		encodedRanges, err := json.Marshal(ranges)
+		if err != nil {
+			return err
+		}
+
+		return s.queryBlocks(
+			"SELECT pos, data FROM blocks JOIN json_each(?) AS bounds "+
+				"ON pos BETWEEN json_extract(bounds.value, '$[0]') AND json_extract(bounds.value, '$[1]')",
+			selector, callback,
+			string(encodedRanges))
+
+	case BlocksAtPositions:
+		positions := make([]int64, len(selector.Positions))
+		for i, pos := range selector.Positions {
+			positions[i] = EncodeBlockPosition(pos)
+		}
+
+		encodedPositions, err := json.Marshal(positions)
+		if err != nil {
+			return err
+		}
+
+		// The query returns exactly the requested blocks, which spares checking
+		// every one of them against the list
+		return s.queryBlocks(
+			"SELECT pos, data FROM blocks WHERE pos IN (SELECT value FROM json_each(?))",
+			anyBlock{}, callback,
+			string(encodedPositions))
+
+	default:
+		return s.queryBlocks("SELECT pos, data FROM blocks", selector, callback)
+	}
+}
+
+// anyBlock selects blocks returned by queries selecting them exactly
+type anyBlock struct{}
+
+func (anyBlock) Contains(geom.BlockPosition) bool {
+	return true
+}
+
+func (s *SQLiteBackend) queryBlocks(
+	query string,
+	selector BlockSelector,
+	callback func(geom.BlockPosition, []byte) error,
+	args ...any,
+) error {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			packedPos int64
+			data      []byte
+		)
+
+		err = rows.Scan(&packedPos, &data)
+		if err != nil {
+			return err
+		}
+
+		pos := DecodeBlockPosition(packedPos)
+		if !selector.Contains(pos) {
+			continue
+		}
+
+		err = callback(pos, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -7,8 +7,6 @@ import (
 	"path/filepath"
 
 	lru "github.com/hashicorp/golang-lru/v2"
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/lord-server/panorama/pkg/geom"
 )
 
@@ -18,80 +16,6 @@ type Backend interface {
 	Close()
 }
 
-type PostgresBackend struct {
-	conn *pgxpool.Pool
-}
-
-func NewPostgresBackend(dsn string) (*PostgresBackend, error) {
-	conn, err := pgxpool.New(context.Background(), dsn)
-	if err != nil {
-		return nil, err
-	}
-
-	return &PostgresBackend{
-		conn: conn,
-	}, nil
-}
-
-func (p *PostgresBackend) Close() {
-	p.conn.Close()
-}
-
-func (p *PostgresBackend) GetBlockData(pos geom.BlockPosition) ([]byte, error) {
-	var data []byte
-
-	err := p.conn.QueryRow(context.Background(), "SELECT data FROM blocks WHERE posx=$1 and posy=$2 and posz=$3", pos.X, pos.Y, pos.Z).Scan(&data)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, nil
-	}
-
-	if err != nil {
-		return nil, err
-	}
-
-	return data, nil
-}
-
-func (p *PostgresBackend) GetBlocks(selector BlockSelector, callback func(geom.BlockPosition, []byte) error) error {
-	sql, args := selector.Query()
-
-	rows, err := p.conn.Query(context.Background(), sql, args...)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return nil
-	}
-
-	if err != nil {
-		return err
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var (
-			pos  geom.BlockPosition
-			data []byte
-		)
-
-		err = rows.Scan(&pos.X, &pos.Y, &pos.Z, &data)
-		if err != nil {
-			return err
-		}
-
-		err = callback(pos, data)
-		if err != nil {
-			return err
-		}
-	}
-
-	rows.Close()
-
-	if err := rows.Err(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
 type World struct {
 	backend           Backend
 	decodedBlockCache *lru.Cache[geom.BlockPosition, *MapBlock]
@@ -123,6 +47,21 @@ func NewWorld(path string) (World, error) {
 		if err != nil {
 			return world, fmt.Errorf("unable to create PostgreSQL backend: %w", err)
 		}
+
+	case "sqlite3":
+		backend, err = NewSQLiteBackend(filepath.Join(path, "map.sqlite"))
+		if err != nil {
+			return world, fmt.Errorf("unable to create SQLite backend: %w", err)
+		}
+
+	case "leveldb":
+		backend, err = NewLevelDBBackend(filepath.Join(path, "map.db"))
+		if err != nil {
+			return world, fmt.Errorf("unable to create LevelDB backend: %w", err)
+		}
+
+	default:
+		return world, fmt.Errorf("unsupported backend: `%s`", backendName)
 	}
 
 	return NewWorldWithBackend(backend), nil
@@ -171,16 +110,23 @@ func (w *World) GetBlock(pos geom.BlockPosition) (*MapBlock, error) {
 	return block, nil
 }
 
+// GetBlocks fetches all blocks matching the selector. Blocks selected with
+// BlocksModifiedSince are always decoded anew, refreshing cached copies of
+// modified blocks only.
 func (w *World) GetBlocks(selector BlockSelector, callback func(geom.BlockPosition, *MapBlock) error) error {
+	modifiedSince, filterByTimestamp := selector.(BlocksModifiedSince)
+
 	return w.backend.GetBlocks(selector, func(pos geom.BlockPosition, data []byte) error {
-		cachedBlock, ok := w.decodedBlockCache.Get(pos)
+		if !filterByTimestamp {
+			cachedBlock, ok := w.decodedBlockCache.Get(pos)
 
-		if ok {
-			if cachedBlock == nil {
-				return nil
-			}
+			if ok {
+				if cachedBlock == nil {
+					return nil
+				}
 
-			return callback(pos, cachedBlock)
+				return callback(pos, cachedBlock)
+			}
 		}
 
 		block, err := DecodeMapBlock(data)
@@ -188,8 +134,82 @@ func (w *World) GetBlocks(selector BlockSelector, callback func(geom.BlockPositi
 			return err
 		}
 
+		// Scanning for modified blocks goes through the whole world, and
+		// caching every block would evict the ones needed for rendering.
+		// Blocks without a timestamp can't be told apart from unmodified ones,
+		// so they are never reported as modified.
+		if filterByTimestamp &&
+			(block.Timestamp() == BlockTimestampUndefined || block.Timestamp() < modifiedSince.Timestamp) {
+			return nil
+		}
+
 		w.decodedBlockCache.Add(pos, block)
 
 		return callback(pos, block)
 	})
 }
+
+// Prefetch loads blocks inside of the cuboid into the cache using a single
+// backend request. Positions without blocks are remembered as empty.
+func (w *World) Prefetch(cuboid BlocksInCuboid) error {
+	missing := make(map[geom.BlockPosition]struct{}, cuboid.Volume())
+
+	_ = cuboid.ForEach(func(pos geom.BlockPosition) error {
+		if !w.decodedBlockCache.Contains(pos) {
+			missing[pos] = struct{}{}
+		}
+
+		return nil
+	})
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	err := w.GetBlocks(cuboid, func(pos geom.BlockPosition, _ *MapBlock) error {
+		delete(missing, pos)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for pos := range missing {
+		w.decodedBlockCache.Add(pos, nil)
+	}
+
+	return nil
+}
+
+// ChangeListener is implemented by backends capable of reporting modified
+// blocks as soon as they are saved
+type ChangeListener interface {
+	InstallChangeTrigger(ctx context.Context) error
+	ListenChanges(ctx context.Context, callback func(geom.BlockPosition)) error
+}
+
+var ErrChangesNotSupported = errors.New("backend doesn't support listening for changes")
+
+// ListenChanges blocks until the context is canceled or the backend fails,
+// calling the callback for every modified block. Cached copies of modified
+// blocks are dropped before the callback is called.
+func (w *World) ListenChanges(ctx context.Context, installTrigger bool, callback func(geom.BlockPosition)) error {
+	listener, ok := w.backend.(ChangeListener)
+	if !ok {
+		return ErrChangesNotSupported
+	}
+
+	if installTrigger {
+		err := listener.InstallChangeTrigger(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return listener.ListenChanges(ctx, func(pos geom.BlockPosition) {
+		w.decodedBlockCache.Remove(pos)
+
+		callback(pos)
+	})
+}
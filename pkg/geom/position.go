@@ -24,6 +24,14 @@ func (lhs NodePosition) Add(rhs NodePosition) NodePosition {
 	}
 }
 
+func (lhs NodePosition) Mul(rhs int) NodePosition {
+	return NodePosition{
+		X: lhs.X * rhs,
+		Y: lhs.Y * rhs,
+		Z: lhs.Z * rhs,
+	}
+}
+
 // BlockPosition is a block position in world space
 type BlockPosition struct {
 	X, Y, Z int
@@ -0,0 +1,141 @@
+package imageutil
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Clone returns a copy of the image with bounds starting at the origin
+func Clone(img *image.NRGBA) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, img.Rect.Dx(), img.Rect.Dy()))
+	draw.Draw(dst, dst.Rect, img, img.Rect.Min, draw.Src)
+
+	return dst
+}
+
+// Crop returns a copy of the given rectangle of the image
+func Crop(img *image.NRGBA, rect image.Rectangle) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Rect, img, img.Rect.Min.Add(rect.Min), draw.Src)
+
+	return dst
+}
+
+// Scale resizes the image using nearest neighbor sampling, which keeps pixel
+// art crisp
+func Scale(img *image.NRGBA, width, height int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	srcWidth := img.Rect.Dx()
+	srcHeight := img.Rect.Dy()
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := img.NRGBAAt(img.Rect.Min.X+x*srcWidth/width, img.Rect.Min.Y+y*srcHeight/height)
+			dst.SetNRGBA(x, y, c)
+		}
+	}
+
+	return dst
+}
+
+// BlendOver composites src over dst in place, placing the top left corner of
+// src at given point of dst
+func BlendOver(dst, src *image.NRGBA, at image.Point) {
+	rect := src.Rect.Sub(src.Rect.Min).Add(at).Intersect(dst.Rect)
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			s := src.NRGBAAt(src.Rect.Min.X+x-at.X, src.Rect.Min.Y+y-at.Y)
+			if s.A == 0 {
+				continue
+			}
+
+			dst.SetNRGBA(x, y, Over(dst.NRGBAAt(x, y), s))
+		}
+	}
+}
+
+// Over returns the result of compositing src over dst
+func Over(dst, src color.NRGBA) color.NRGBA {
+	if src.A == 255 {
+		return src
+	}
+
+	srcAlpha := float64(src.A) / 255
+	dstAlpha := float64(dst.A) / 255 * (1 - srcAlpha)
+	alpha := srcAlpha + dstAlpha
+
+	if alpha == 0 {
+		return color.NRGBA{}
+	}
+
+	mix := func(s, d uint8) uint8 {
+		return uint8((float64(s)*srcAlpha + float64(d)*dstAlpha) / alpha)
+	}
+
+	return color.NRGBA{
+		R: mix(src.R, dst.R),
+		G: mix(src.G, dst.G),
+		B: mix(src.B, dst.B),
+		A: uint8(alpha * 255),
+	}
+}
+
+// Rotate90 rotates the image by 90 degrees counter-clockwise
+func Rotate90(img *image.NRGBA) *image.NRGBA {
+	width := img.Rect.Dx()
+	height := img.Rect.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, height, width))
+
+	for y := 0; y < width; y++ {
+		for x := 0; x < height; x++ {
+			dst.SetNRGBA(x, y, img.NRGBAAt(img.Rect.Min.X+width-1-y, img.Rect.Min.Y+x))
+		}
+	}
+
+	return dst
+}
+
+// FlipX mirrors the image horizontally
+func FlipX(img *image.NRGBA) *image.NRGBA {
+	width := img.Rect.Dx()
+	height := img.Rect.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			dst.SetNRGBA(x, y, img.NRGBAAt(img.Rect.Min.X+width-1-x, img.Rect.Min.Y+y))
+		}
+	}
+
+	return dst
+}
+
+// FlipY mirrors the image vertically
+func FlipY(img *image.NRGBA) *image.NRGBA {
+	width := img.Rect.Dx()
+	height := img.Rect.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			dst.SetNRGBA(x, y, img.NRGBAAt(img.Rect.Min.X+x, img.Rect.Min.Y+height-1-y))
+		}
+	}
+
+	return dst
+}
+
+// MapColors returns a copy of the image with fn applied to every pixel
+func MapColors(img *image.NRGBA, fn func(color.NRGBA) color.NRGBA) *image.NRGBA {
+	dst := Clone(img)
+
+	for i := 0; i < len(dst.Pix); i += 4 {
+		c := fn(color.NRGBA{R: dst.Pix[i], G: dst.Pix[i+1], B: dst.Pix[i+2], A: dst.Pix[i+3]})
+
+		dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+
+	return dst
+}
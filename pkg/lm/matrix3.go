@@ -31,3 +31,19 @@ func (lhs *Matrix3) MulVec(rhs Vector3) Vector3 {
 
 	return Vec3(x, y, z)
 }
+
+func (lhs *Matrix3) Determinant() float64 {
+	m := &lhs.m
+
+	return m[0]*(m[4]*m[8]-m[5]*m[7]) - m[1]*(m[3]*m[8]-m[5]*m[6]) + m[2]*(m[3]*m[7]-m[4]*m[6])
+}
+
+func (lhs *Matrix3) Cofactor() Matrix3 {
+	m := &lhs.m
+
+	return NewMatrix3([9]float64{
+		m[4]*m[8] - m[5]*m[7], m[5]*m[6] - m[3]*m[8], m[3]*m[7] - m[4]*m[6],
+		m[2]*m[7] - m[1]*m[8], m[0]*m[8] - m[2]*m[6], m[1]*m[6] - m[0]*m[7],
+		m[1]*m[5] - m[2]*m[4], m[2]*m[3] - m[0]*m[5], m[0]*m[4] - m[1]*m[3],
+	})
+}
@@ -22,6 +22,22 @@ func (lhs Vector3) Add(rhs Vector3) Vector3 {
 	return Vec3(x, y, z)
 }
 
+func (lhs Vector3) Sub(rhs Vector3) Vector3 {
+	x := lhs.X - rhs.X
+	y := lhs.Y - rhs.Y
+	z := lhs.Z - rhs.Z
+
+	return Vec3(x, y, z)
+}
+
+func (lhs Vector3) Mul(rhs Vector3) Vector3 {
+	x := lhs.X * rhs.X
+	y := lhs.Y * rhs.Y
+	z := lhs.Z * rhs.Z
+
+	return Vec3(x, y, z)
+}
+
 func (lhs Vector3) MulScalar(rhs float64) Vector3 {
 	x := lhs.X * rhs
 	y := lhs.Y * rhs
@@ -79,6 +95,14 @@ func (lhs Vector3) XY() Vector2 {
 	return Vec2(lhs.X, lhs.Y)
 }
 
+func (lhs Vector3) Max(rhs Vector3) Vector3 {
+	x := math.Max(lhs.X, rhs.X)
+	y := math.Max(lhs.Y, rhs.Y)
+	z := math.Max(lhs.Z, rhs.Z)
+
+	return Vec3(x, y, z)
+}
+
 func (lhs Vector3) MaxComponent() float64 {
 	return math.Max(lhs.X, math.Max(lhs.Y, lhs.Z))
 }
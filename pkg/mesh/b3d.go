@@ -0,0 +1,277 @@
+package mesh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lord-server/panorama/pkg/lm"
+)
+
+const (
+	b3dVertexHasNormal = 1 << 0
+	b3dVertexHasColor  = 1 << 1
+)
+
+var errInvalidB3D = errors.New("not a B3D file")
+
+// b3dParser reads the static pose of a Blitz3D model. Every TRIS chunk
+// becomes a separate mesh, in the order they appear in the file, which is how
+// tiles are assigned to them.
+type b3dParser struct {
+	r     *bytes.Reader
+	model Model
+}
+
+func (p *b3dParser) offset() int64 {
+	return p.r.Size() - int64(p.r.Len())
+}
+
+func (p *b3dParser) read(values ...any) error {
+	for _, value := range values {
+		if err := binary.Read(p.r, binary.LittleEndian, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *b3dParser) skipString() error {
+	for {
+		b, err := p.r.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		if b == 0 {
+			return nil
+		}
+	}
+}
+
+// readChunk reads a chunk header, returning its tag and the offset of its end
+func (p *b3dParser) readChunk() (string, int64, error) {
+	var (
+		tag    [4]byte
+		length int32
+	)
+
+	if err := p.read(&tag, &length); err != nil {
+		return "", 0, err
+	}
+
+	end := p.offset() + int64(length)
+	if length < 0 || end > p.r.Size() {
+		return "", 0, fmt.Errorf("chunk %s exceeds file size", tag[:])
+	}
+
+	return string(tag[:]), end, nil
+}
+
+// readChunks calls handle for every chunk until the end offset, skipping
+// whatever the handler leaves unread
+func (p *b3dParser) readChunks(end int64, handle func(tag string, end int64) error) error {
+	for p.offset() < end {
+		tag, chunkEnd, err := p.readChunk()
+		if err != nil {
+			return err
+		}
+
+		if chunkEnd > end {
+			return fmt.Errorf("chunk %s exceeds its parent", tag)
+		}
+
+		if err := handle(tag, chunkEnd); err != nil {
+			return fmt.Errorf("%s: %w", tag, err)
+		}
+
+		if _, err := p.r.Seek(chunkEnd, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *b3dParser) readNode(end int64, parent transform) error {
+	var position, scale [3]float32
+
+	// Rotation is stored as W, X, Y and Z components of a quaternion
+	var rotation [4]float32
+
+	if err := p.skipString(); err != nil {
+		return err
+	}
+
+	if err := p.read(&position, &scale, &rotation); err != nil {
+		return err
+	}
+
+	nodeTransform := parent.then(newTransform(
+		lm.Vec3(float64(position[0]), float64(position[1]), float64(position[2])),
+		lm.Vec4(float64(rotation[1]), float64(rotation[2]), float64(rotation[3]), float64(rotation[0])),
+		lm.Vec3(float64(scale[0]), float64(scale[1]), float64(scale[2])),
+	))
+
+	return p.readChunks(end, func(tag string, end int64) error {
+		switch tag {
+		case "MESH":
+			return p.readMesh(end, nodeTransform)
+		case "NODE":
+			return p.readNode(end, nodeTransform)
+		default:
+			return nil
+		}
+	})
+}
+
+func (p *b3dParser) readMesh(end int64, t transform) error {
+	var brush int32
+	if err := p.read(&brush); err != nil {
+		return err
+	}
+
+	var vertices []Vertex
+
+	return p.readChunks(end, func(tag string, end int64) error {
+		var err error
+
+		switch tag {
+		case "VRTS":
+			vertices, err = p.readVertices(end, t)
+		case "TRIS":
+			err = p.readTriangles(end, vertices)
+		}
+
+		return err
+	})
+}
+
+func (p *b3dParser) readVertices(end int64, t transform) ([]Vertex, error) {
+	var flags, texcoordSets, texcoordSize int32
+	if err := p.read(&flags, &texcoordSets, &texcoordSize); err != nil {
+		return nil, err
+	}
+
+	if texcoordSets < 0 || texcoordSize < 0 {
+		return nil, errors.New("invalid texture coordinates layout")
+	}
+
+	normalOffset := 3
+	colorOffset := normalOffset
+
+	if flags&b3dVertexHasNormal != 0 {
+		colorOffset += 3
+	}
+
+	texcoordOffset := colorOffset
+	if flags&b3dVertexHasColor != 0 {
+		texcoordOffset += 4
+	}
+
+	values := make([]float32, texcoordOffset+int(texcoordSets*texcoordSize))
+
+	var vertices []Vertex
+
+	for p.offset() < end {
+		if err := p.read(values); err != nil {
+			return nil, err
+		}
+
+		vertex := Vertex{
+			Position: t.apply(lm.Vec3(float64(values[0]), float64(values[1]), float64(values[2]))),
+		}
+
+		if flags&b3dVertexHasNormal != 0 {
+			normal := lm.Vec3(float64(values[normalOffset]), float64(values[normalOffset+1]), float64(values[normalOffset+2]))
+			vertex.Normal = t.applyNormal(normal)
+		}
+
+		if texcoordSets > 0 && texcoordSize >= 2 {
+			vertex.Texcoord = lm.Vec2(float64(values[texcoordOffset]), float64(values[texcoordOffset+1]))
+		}
+
+		vertices = append(vertices, vertex)
+	}
+
+	return vertices, nil
+}
+
+func (p *b3dParser) readTriangles(end int64, vertices []Vertex) error {
+	var brush int32
+	if err := p.read(&brush); err != nil {
+		return err
+	}
+
+	mesh := NewMesh()
+
+	for p.offset() < end {
+		var indices [3]int32
+		if err := p.read(&indices); err != nil {
+			return err
+		}
+
+		for _, index := range indices {
+			if index < 0 || int(index) >= len(vertices) {
+				return fmt.Errorf("vertex index %d out of range", index)
+			}
+
+			mesh.Vertices = append(mesh.Vertices, vertices[index])
+		}
+	}
+
+	mesh.fillFaceNormals()
+	p.model.Meshes = append(p.model.Meshes, mesh)
+
+	return nil
+}
+
+// ParseB3D reads a model in the Blitz3D format. Animations are ignored, and
+// the model is loaded in its rest pose.
+func ParseB3D(data []byte) (Model, error) {
+	parser := b3dParser{
+		r:     bytes.NewReader(data),
+		model: NewModel(),
+	}
+
+	tag, end, err := parser.readChunk()
+	if err != nil || tag != "BB3D" {
+		return Model{}, errInvalidB3D
+	}
+
+	var version int32
+	if err := parser.read(&version); err != nil {
+		return Model{}, err
+	}
+
+	err = parser.readChunks(end, func(tag string, end int64) error {
+		if tag == "NODE" {
+			return parser.readNode(end, identityTransform())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return Model{}, err
+	}
+
+	return parser.model, nil
+}
+
+func LoadB3D(path string) (Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Model{}, err
+	}
+
+	model, err := ParseB3D(data)
+	if err != nil {
+		return Model{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return model, nil
+}
@@ -0,0 +1,71 @@
+package mesh
+
+import "github.com/lord-server/panorama/pkg/lm"
+
+// FireNeighbors is a set of directions in which a fire has solid neighbors
+type FireNeighbors uint8
+
+const (
+	FireNeighborNorth FireNeighbors = 1 << iota
+	FireNeighborTop
+	FireNeighborEast
+	FireNeighborSouth
+	FireNeighborDown
+	FireNeighborWest
+)
+
+func fireQuad(scale, rotation, openingAngle, offsetH, offsetV float64) []Vertex {
+	corners := [4]lm.Vector3{
+		lm.Vec3(-scale, -0.5+scale*2, 0),
+		lm.Vec3(scale, -0.5+scale*2, 0),
+		lm.Vec3(scale, -0.5, 0),
+		lm.Vec3(-scale, -0.5, 0),
+	}
+
+	for i, corner := range corners {
+		corner = corner.RotateYZ(lm.Radians(openingAngle))
+		corner.Z += offsetH
+		corner = corner.RotateXZ(lm.Radians(rotation))
+		corner.Y += offsetV
+
+		corners[i] = corner
+	}
+
+	return Quad(corners)
+}
+
+// Firelike generates flames burning on the floor, or climbing neighboring
+// walls and hanging from the ceiling. Scale is a half of the flame width.
+func Firelike(neighbors FireNeighbors, scale float64) *Model {
+	basic := neighbors&FireNeighborDown != 0 || neighbors == 0
+	bottom := neighbors&FireNeighborTop != 0
+
+	sides := []struct {
+		rotation float64
+		neighbor FireNeighbors
+	}{
+		{0, FireNeighborNorth},
+		{90, FireNeighborWest},
+		{180, FireNeighborSouth},
+		{270, FireNeighborEast},
+	}
+
+	mesh := NewMesh()
+
+	for _, side := range sides {
+		if basic || neighbors&side.neighbor != 0 {
+			mesh.Vertices = append(mesh.Vertices, fireQuad(scale, side.rotation, -10, 0.4, 0)...)
+		} else if bottom {
+			mesh.Vertices = append(mesh.Vertices, fireQuad(scale, side.rotation, 70, 0.47, 0.484)...)
+		}
+	}
+
+	if basic {
+		mesh.Vertices = append(mesh.Vertices, fireQuad(scale, 45, 0, 0, 0)...)
+		mesh.Vertices = append(mesh.Vertices, fireQuad(scale, -45, 0, 0, 0)...)
+	}
+
+	return &Model{
+		Meshes: []Mesh{mesh},
+	}
+}
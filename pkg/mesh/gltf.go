@@ -0,0 +1,512 @@
+package mesh
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lord-server/panorama/pkg/lm"
+)
+
+const (
+	glbMagic     = 0x46546C67
+	glbChunkJSON = 0x4E4F534A
+	glbChunkBIN  = 0x004E4942
+
+	gltfModeTriangles = 4
+
+	gltfByte          = 5120
+	gltfUnsignedByte  = 5121
+	gltfShort         = 5122
+	gltfUnsignedShort = 5123
+	gltfUnsignedInt   = 5125
+	gltfFloat         = 5126
+)
+
+var gltfComponentCounts = map[string]int{
+	"SCALAR": 1,
+	"VEC2":   2,
+	"VEC3":   3,
+	"VEC4":   4,
+}
+
+type gltfNode struct {
+	Children    []int     `json:"children"`
+	Mesh        *int      `json:"mesh"`
+	Matrix      []float64 `json:"matrix"`
+	Translation []float64 `json:"translation"`
+	Rotation    []float64 `json:"rotation"`
+	Scale       []float64 `json:"scale"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    *int           `json:"indices"`
+	Mode       *int           `json:"mode"`
+}
+
+type gltfAccessor struct {
+	BufferView    *int   `json:"bufferView"`
+	ByteOffset    int    `json:"byteOffset"`
+	ComponentType int    `json:"componentType"`
+	Normalized    bool   `json:"normalized"`
+	Count         int    `json:"count"`
+	Type          string `json:"type"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	ByteStride int `json:"byteStride"`
+}
+
+type gltfDocument struct {
+	Scene  *int `json:"scene"`
+	Scenes []struct {
+		Nodes []int `json:"nodes"`
+	} `json:"scenes"`
+	Nodes  []gltfNode `json:"nodes"`
+	Meshes []struct {
+		Primitives []gltfPrimitive `json:"primitives"`
+	} `json:"meshes"`
+	Accessors   []gltfAccessor   `json:"accessors"`
+	BufferViews []gltfBufferView `json:"bufferViews"`
+	Buffers     []struct {
+		URI string `json:"uri"`
+	} `json:"buffers"`
+
+	buffers [][]byte
+}
+
+// loadBuffers resolves buffers embedded as data URIs, stored in files next
+// to the model or, for binary glTF, in the BIN chunk
+func (d *gltfDocument) loadBuffers(dir string, bin []byte) error {
+	d.buffers = make([][]byte, len(d.Buffers))
+
+	for i, buffer := range d.Buffers {
+		switch {
+		case buffer.URI == "" && i == 0 && bin != nil:
+			d.buffers[i] = bin
+
+		case strings.HasPrefix(buffer.URI, "data:"):
+			_, encoded, ok := strings.Cut(buffer.URI, ";base64,")
+			if !ok {
+				return fmt.Errorf("buffer %d: unsupported data URI", i)
+			}
+
+			data, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return fmt.Errorf("buffer %d: %w", i, err)
+			}
+
+			d.buffers[i] = data
+
+		default:
+			name, err := url.PathUnescape(buffer.URI)
+			if err != nil {
+				return fmt.Errorf("buffer %d: %w", i, err)
+			}
+
+			data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+			if err != nil {
+				return fmt.Errorf("buffer %d: %w", i, err)
+			}
+
+			d.buffers[i] = data
+		}
+	}
+
+	return nil
+}
+
+func readGLTFComponent(data []byte, componentType int, normalized bool) (float64, int, error) {
+	var value, scale float64
+
+	size := 0
+
+	switch componentType {
+	case gltfByte:
+		size, value, scale = 1, float64(int8(data[0])), math.MaxInt8
+	case gltfUnsignedByte:
+		size, value, scale = 1, float64(data[0]), math.MaxUint8
+	case gltfShort:
+		size, value, scale = 2, float64(int16(binary.LittleEndian.Uint16(data))), math.MaxInt16
+	case gltfUnsignedShort:
+		size, value, scale = 2, float64(binary.LittleEndian.Uint16(data)), math.MaxUint16
+	case gltfUnsignedInt:
+		size, value, scale = 4, float64(binary.LittleEndian.Uint32(data)), math.MaxUint32
+	case gltfFloat:
+		size, value, scale = 4, float64(math.Float32frombits(binary.LittleEndian.Uint32(data))), 1
+	default:
+		return 0, 0, fmt.Errorf("unsupported component type %d", componentType)
+	}
+
+	if normalized {
+		value = max(value/scale, -1)
+	}
+
+	return value, size, nil
+}
+
+func gltfComponentSize(componentType int) int {
+	switch componentType {
+	case gltfByte, gltfUnsignedByte:
+		return 1
+	case gltfShort, gltfUnsignedShort:
+		return 2
+	default:
+		return 4
+	}
+}
+
+// readAccessor returns elements of the accessor as slices of components
+func (d *gltfDocument) readAccessor(index int) ([][]float64, error) {
+	if index < 0 || index >= len(d.Accessors) {
+		return nil, fmt.Errorf("accessor %d doesn't exist", index)
+	}
+
+	accessor := d.Accessors[index]
+
+	components, ok := gltfComponentCounts[accessor.Type]
+	if !ok {
+		return nil, fmt.Errorf("accessor %d: unsupported type %s", index, accessor.Type)
+	}
+
+	elements := make([][]float64, accessor.Count)
+
+	// Accessors without a buffer view are filled with zeros
+	if accessor.BufferView == nil {
+		for i := range elements {
+			elements[i] = make([]float64, components)
+		}
+
+		return elements, nil
+	}
+
+	if *accessor.BufferView < 0 || *accessor.BufferView >= len(d.BufferViews) {
+		return nil, fmt.Errorf("accessor %d: buffer view %d doesn't exist", index, *accessor.BufferView)
+	}
+
+	view := d.BufferViews[*accessor.BufferView]
+	if view.Buffer < 0 || view.Buffer >= len(d.buffers) {
+		return nil, fmt.Errorf("buffer view %d: buffer %d doesn't exist", *accessor.BufferView, view.Buffer)
+	}
+
+	componentSize := gltfComponentSize(accessor.ComponentType)
+
+	stride := view.ByteStride
+	if stride == 0 {
+		stride = components * componentSize
+	}
+
+	buffer := d.buffers[view.Buffer]
+	start := view.ByteOffset + accessor.ByteOffset
+
+	if accessor.Count > 0 {
+		last := start + (accessor.Count-1)*stride + components*componentSize
+		if start < 0 || last > view.ByteOffset+view.ByteLength || last > len(buffer) {
+			return nil, fmt.Errorf("accessor %d exceeds its buffer", index)
+		}
+	}
+
+	for i := range elements {
+		element := make([]float64, components)
+		offset := start + i*stride
+
+		for j := range element {
+			value, size, err := readGLTFComponent(buffer[offset:], accessor.ComponentType, accessor.Normalized)
+			if err != nil {
+				return nil, fmt.Errorf("accessor %d: %w", index, err)
+			}
+
+			element[j] = value
+			offset += size
+		}
+
+		elements[i] = element
+	}
+
+	return elements, nil
+}
+
+func (n *gltfNode) transform() transform {
+	if len(n.Matrix) == 16 {
+		m := n.Matrix
+
+		// Matrices are stored in column-major order
+		return transform{
+			linear:      lm.NewMatrix3([9]float64{m[0], m[4], m[8], m[1], m[5], m[9], m[2], m[6], m[10]}),
+			translation: lm.Vec3(m[12], m[13], m[14]),
+		}
+	}
+
+	translation := lm.Vec3(0, 0, 0)
+	if len(n.Translation) == 3 {
+		translation = lm.Vec3(n.Translation[0], n.Translation[1], n.Translation[2])
+	}
+
+	rotation := lm.Vec4(0, 0, 0, 1)
+	if len(n.Rotation) == 4 {
+		rotation = lm.Vec4(n.Rotation[0], n.Rotation[1], n.Rotation[2], n.Rotation[3])
+	}
+
+	scale := lm.Vec3(1, 1, 1)
+	if len(n.Scale) == 3 {
+		scale = lm.Vec3(n.Scale[0], n.Scale[1], n.Scale[2])
+	}
+
+	return newTransform(translation, rotation, scale)
+}
+
+// readPrimitive converts a primitive to a mesh. glTF is right-handed, so the
+// Z axis is flipped, and triangles are reversed to keep facing outwards.
+func (d *gltfDocument) readPrimitive(primitive gltfPrimitive, t transform) (Mesh, error) {
+	positionIndex, ok := primitive.Attributes["POSITION"]
+	if !ok {
+		return Mesh{}, errors.New("primitive has no positions")
+	}
+
+	positions, err := d.readAccessor(positionIndex)
+	if err != nil {
+		return Mesh{}, err
+	}
+
+	vertices := make([]Vertex, len(positions))
+
+	for i, position := range positions {
+		p := t.apply(lm.Vec3(position[0], position[1], position[2]))
+		vertices[i].Position = lm.Vec3(p.X, p.Y, -p.Z)
+	}
+
+	if index, ok := primitive.Attributes["NORMAL"]; ok {
+		normals, err := d.readAccessor(index)
+		if err != nil {
+			return Mesh{}, err
+		}
+
+		for i := range vertices {
+			if i < len(normals) {
+				n := t.applyNormal(lm.Vec3(normals[i][0], normals[i][1], normals[i][2]))
+				vertices[i].Normal = lm.Vec3(n.X, n.Y, -n.Z)
+			}
+		}
+	}
+
+	if index, ok := primitive.Attributes["TEXCOORD_0"]; ok {
+		texcoords, err := d.readAccessor(index)
+		if err != nil {
+			return Mesh{}, err
+		}
+
+		for i := range vertices {
+			if i < len(texcoords) {
+				vertices[i].Texcoord = lm.Vec2(texcoords[i][0], texcoords[i][1])
+			}
+		}
+	}
+
+	var indices []int
+
+	if primitive.Indices == nil {
+		for i := range vertices {
+			indices = append(indices, i)
+		}
+	} else {
+		elements, err := d.readAccessor(*primitive.Indices)
+		if err != nil {
+			return Mesh{}, err
+		}
+
+		for _, element := range elements {
+			indices = append(indices, int(element[0]))
+		}
+	}
+
+	mesh := NewMesh()
+
+	for i := 0; i+2 < len(indices); i += 3 {
+		for _, index := range [3]int{indices[i], indices[i+2], indices[i+1]} {
+			if index < 0 || index >= len(vertices) {
+				return Mesh{}, fmt.Errorf("vertex index %d out of range", index)
+			}
+
+			mesh.Vertices = append(mesh.Vertices, vertices[index])
+		}
+	}
+
+	mesh.fillFaceNormals()
+
+	return mesh, nil
+}
+
+// readNode appends meshes of the node and its children to the model. Every
+// triangle primitive becomes a separate mesh.
+func (d *gltfDocument) readNode(model *Model, index int, parent transform, depth int) error {
+	if index < 0 || index >= len(d.Nodes) || depth > len(d.Nodes) {
+		return fmt.Errorf("invalid node %d", index)
+	}
+
+	node := d.Nodes[index]
+	t := parent.then(node.transform())
+
+	if node.Mesh != nil {
+		if *node.Mesh < 0 || *node.Mesh >= len(d.Meshes) {
+			return fmt.Errorf("node %d: mesh %d doesn't exist", index, *node.Mesh)
+		}
+
+		for _, primitive := range d.Meshes[*node.Mesh].Primitives {
+			if primitive.Mode != nil && *primitive.Mode != gltfModeTriangles {
+				continue
+			}
+
+			mesh, err := d.readPrimitive(primitive, t)
+			if err != nil {
+				return fmt.Errorf("mesh %d: %w", *node.Mesh, err)
+			}
+
+			model.Meshes = append(model.Meshes, mesh)
+		}
+	}
+
+	for _, child := range node.Children {
+		if err := d.readNode(model, child, t, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rootNodes returns nodes of the default scene, or every node without a
+// parent if the file doesn't define scenes
+func (d *gltfDocument) rootNodes() []int {
+	if len(d.Scenes) != 0 {
+		scene := 0
+		if d.Scene != nil && *d.Scene >= 0 && *d.Scene < len(d.Scenes) {
+			scene = *d.Scene
+		}
+
+		return d.Scenes[scene].Nodes
+	}
+
+	isChild := make([]bool, len(d.Nodes))
+
+	for _, node := range d.Nodes {
+		for _, child := range node.Children {
+			if child >= 0 && child < len(isChild) {
+				isChild[child] = true
+			}
+		}
+	}
+
+	var roots []int
+
+	for i := range d.Nodes {
+		if !isChild[i] {
+			roots = append(roots, i)
+		}
+	}
+
+	return roots
+}
+
+// splitGLB returns the JSON and BIN chunks of a binary glTF file
+func splitGLB(data []byte) ([]byte, []byte, error) {
+	const headerSize = 12
+
+	if len(data) < headerSize || binary.LittleEndian.Uint32(data) != glbMagic {
+		return nil, nil, errors.New("not a binary glTF file")
+	}
+
+	var jsonChunk, binChunk []byte
+
+	reader := bytes.NewReader(data[headerSize:])
+
+	for reader.Len() > 0 {
+		var header struct {
+			Length uint32
+			Type   uint32
+		}
+
+		if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+			return nil, nil, err
+		}
+
+		if int64(header.Length) > int64(reader.Len()) {
+			return nil, nil, errors.New("chunk exceeds file size")
+		}
+
+		chunk := make([]byte, header.Length)
+		_, _ = reader.Read(chunk)
+
+		switch header.Type {
+		case glbChunkJSON:
+			jsonChunk = chunk
+		case glbChunkBIN:
+			binChunk = chunk
+		}
+	}
+
+	if jsonChunk == nil {
+		return nil, nil, errors.New("missing JSON chunk")
+	}
+
+	return jsonChunk, binChunk, nil
+}
+
+// ParseGLTF reads the static pose of a glTF 2.0 model, given either as JSON
+// or in the binary form. External buffers are looked up in dir.
+func ParseGLTF(data []byte, dir string) (Model, error) {
+	var bin []byte
+
+	if len(data) >= 4 && binary.LittleEndian.Uint32(data) == glbMagic {
+		var err error
+
+		data, bin, err = splitGLB(data)
+		if err != nil {
+			return Model{}, err
+		}
+	}
+
+	var document gltfDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		return Model{}, err
+	}
+
+	if err := document.loadBuffers(dir, bin); err != nil {
+		return Model{}, err
+	}
+
+	model := NewModel()
+
+	for _, root := range document.rootNodes() {
+		if err := document.readNode(&model, root, identityTransform(), 0); err != nil {
+			return Model{}, err
+		}
+	}
+
+	return model, nil
+}
+
+func LoadGLTF(path string) (Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Model{}, err
+	}
+
+	model, err := ParseGLTF(data, filepath.Dir(path))
+	if err != nil {
+		return Model{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return model, nil
+}
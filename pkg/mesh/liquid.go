@@ -0,0 +1,105 @@
+package mesh
+
+import (
+	"math"
+
+	"github.com/lord-server/panorama/pkg/lm"
+)
+
+// LiquidCorners are heights of a liquid surface at corners of the node,
+// indexed by Z and then by X, with 0 standing for the negative side
+type LiquidCorners [2][2]float64
+
+// flowTexcoords returns texture coordinates of the liquid surface rotated to
+// follow the slope, from the higher corners to the lower ones
+func flowTexcoords(corners LiquidCorners) [4]lm.Vector2 {
+	dz := (corners[0][0] + corners[0][1]) - (corners[1][0] + corners[1][1])
+	dx := (corners[0][0] + corners[1][0]) - (corners[0][1] + corners[1][1])
+	angle := math.Atan2(dz, dx)
+
+	cos := math.Cos(angle)
+	sin := math.Sin(angle)
+	center := lm.Vec2(0.5, 0.5)
+
+	texcoords := [4]lm.Vector2{
+		lm.Vec2(0, 0),
+		lm.Vec2(1, 0),
+		lm.Vec2(1, 1),
+		lm.Vec2(0, 1),
+	}
+
+	for i, texcoord := range texcoords {
+		v := texcoord.Sub(center)
+		texcoords[i] = lm.Vec2(v.X*cos-v.Y*sin, v.X*sin+v.Y*cos).Add(center)
+	}
+
+	return texcoords
+}
+
+// liquidSide returns a side face reaching from the bottom of the node up to
+// the liquid surface. Texture is aligned to the top of the node.
+func liquidSide(topLeft, topRight lm.Vector3) []Vertex {
+	corners := [4]lm.Vector3{
+		topLeft,
+		topRight,
+		lm.Vec3(topRight.X, -0.5, topRight.Z),
+		lm.Vec3(topLeft.X, -0.5, topLeft.Z),
+	}
+
+	texcoords := [4]lm.Vector2{
+		lm.Vec2(0, 0.5-topLeft.Y),
+		lm.Vec2(1, 0.5-topRight.Y),
+		lm.Vec2(1, 1),
+		lm.Vec2(0, 1),
+	}
+
+	return TexturedQuad(corners, texcoords)
+}
+
+// Liquid generates a flowing liquid with its surface sloped between given
+// corner heights. The first mesh contains the top and bottom faces, and the
+// second one contains the sides.
+func Liquid(corners LiquidCorners, hiddenFaces CubeFaces) *Model {
+	surface := NewMesh()
+	sides := NewMesh()
+
+	if hiddenFaces&CubeFaceTop == 0 {
+		top := [4]lm.Vector3{
+			lm.Vec3(-0.5, corners[1][0], 0.5),
+			lm.Vec3(0.5, corners[1][1], 0.5),
+			lm.Vec3(0.5, corners[0][1], -0.5),
+			lm.Vec3(-0.5, corners[0][0], -0.5),
+		}
+
+		surface.Vertices = append(surface.Vertices, TexturedQuad(top, flowTexcoords(corners))...)
+	}
+
+	if hiddenFaces&CubeFaceDown == 0 {
+		surface.Vertices = append(surface.Vertices, Quad([4]lm.Vector3{
+			lm.Vec3(-0.5, -0.5, -0.5),
+			lm.Vec3(0.5, -0.5, -0.5),
+			lm.Vec3(0.5, -0.5, 0.5),
+			lm.Vec3(-0.5, -0.5, 0.5),
+		})...)
+	}
+
+	faces := []struct {
+		face              CubeFaces
+		topLeft, topRight lm.Vector3
+	}{
+		{CubeFaceEast, lm.Vec3(0.5, corners[0][1], -0.5), lm.Vec3(0.5, corners[1][1], 0.5)},
+		{CubeFaceWest, lm.Vec3(-0.5, corners[1][0], 0.5), lm.Vec3(-0.5, corners[0][0], -0.5)},
+		{CubeFaceNorth, lm.Vec3(0.5, corners[1][1], 0.5), lm.Vec3(-0.5, corners[1][0], 0.5)},
+		{CubeFaceSouth, lm.Vec3(-0.5, corners[0][0], -0.5), lm.Vec3(0.5, corners[0][1], -0.5)},
+	}
+
+	for _, face := range faces {
+		if hiddenFaces&face.face == 0 {
+			sides.Vertices = append(sides.Vertices, liquidSide(face.topLeft, face.topRight)...)
+		}
+	}
+
+	return &Model{
+		Meshes: []Mesh{surface, sides},
+	}
+}
@@ -1,6 +1,8 @@
 package mesh
 
 import (
+	"path/filepath"
+
 	"github.com/lord-server/panorama/pkg/lm"
 )
 
@@ -29,3 +31,31 @@ func NewModel() Model {
 		Meshes: []Mesh{},
 	}
 }
+
+// Load reads the model in the format given by the file extension. Files with
+// unknown extensions are read as OBJ.
+func Load(path string) (Model, error) {
+	switch filepath.Ext(path) {
+	case ".b3d":
+		return LoadB3D(path)
+	case ".gltf", ".glb":
+		return LoadGLTF(path)
+	default:
+		return LoadOBJ(path)
+	}
+}
+
+// fillFaceNormals assigns normals of their triangles to vertices which don't
+// have one
+func (m *Mesh) fillFaceNormals() {
+	for i := 0; i+2 < len(m.Vertices); i += 3 {
+		a, b, c := &m.Vertices[i], &m.Vertices[i+1], &m.Vertices[i+2]
+		normal := b.Position.Sub(a.Position).Cross(c.Position.Sub(a.Position)).Normalize()
+
+		for _, vertex := range []*Vertex{a, b, c} {
+			if vertex.Normal == (lm.Vector3{}) {
+				vertex.Normal = normal
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package mesh
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lord-server/panorama/pkg/lm"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// Closed convex models, all faces of which must face away from their center
+var solidModels = map[string]bool{
+	"chest.obj":    true,
+	"cube.obj":     true,
+	"pyramid.b3d":  true,
+	"pyramid.glb":  true,
+	"pyramid.gltf": true,
+}
+
+// dumpModel formats every vertex of the model, rounding away float noise
+func dumpModel(model Model) string {
+	var b strings.Builder
+
+	for i, mesh := range model.Meshes {
+		fmt.Fprintf(&b, "mesh %d: %d vertices\n", i, len(mesh.Vertices))
+
+		for _, v := range mesh.Vertices {
+			fmt.Fprintf(&b, "  p %.4f %.4f %.4f  t %.4f %.4f  n %.4f %.4f %.4f\n",
+				v.Position.X, v.Position.Y, v.Position.Z,
+				v.Texcoord.X, v.Texcoord.Y,
+				v.Normal.X, v.Normal.Y, v.Normal.Z)
+		}
+	}
+
+	// Negative zero depends on the order of operations
+	return strings.ReplaceAll(b.String(), "-0.0000", "0.0000")
+}
+
+func TestModelsGolden(t *testing.T) {
+	paths, err := filepath.Glob("testdata/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		if filepath.Ext(path) == ".golden" {
+			continue
+		}
+
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			model, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := dumpModel(model)
+			goldenPath := path + ".golden"
+
+			if *update {
+				if err := os.WriteFile(goldenPath, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != string(want) {
+				t.Errorf("model doesn't match %s, run tests with -update to regenerate\n%s", goldenPath, got)
+			}
+		})
+	}
+}
+
+// TestModelNormals checks that normals agree with the winding of triangles,
+// which goldens alone wouldn't catch
+func TestModelNormals(t *testing.T) {
+	paths, err := filepath.Glob("testdata/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		if filepath.Ext(path) == ".golden" {
+			continue
+		}
+
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			model, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var center lm.Vector3
+
+			count := 0
+
+			for _, mesh := range model.Meshes {
+				for _, v := range mesh.Vertices {
+					center = center.Add(v.Position)
+					count++
+				}
+			}
+
+			center = center.MulScalar(1 / float64(count))
+
+			for i, mesh := range model.Meshes {
+				for j := 0; j+2 < len(mesh.Vertices); j += 3 {
+					a, b, c := mesh.Vertices[j], mesh.Vertices[j+1], mesh.Vertices[j+2]
+					face := b.Position.Sub(a.Position).Cross(c.Position.Sub(a.Position))
+
+					for _, v := range []Vertex{a, b, c} {
+						if v.Normal.Dot(face) <= 0 {
+							t.Errorf("mesh %d, triangle %d: normal %v disagrees with winding", i, j/3, v.Normal)
+						}
+					}
+
+					centroid := a.Position.Add(b.Position).Add(c.Position).MulScalar(1.0 / 3)
+					if solidModels[filepath.Base(path)] && face.Dot(centroid.Sub(center)) <= 0 {
+						t.Errorf("mesh %d, triangle %d faces inwards", i, j/3)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestOBJErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"v 0 0 0\nv 1 0 0\nf 1 2\n", "model.obj:3: face needs at least 3 fields, got 2"},
+		{"v 0 0 0\n\nf 1 2 4\n", "model.obj:3: position index 2 out of range"},
+		{"v 0 0 0\nv 1 0 0\nv 1 1 0\nf -1 -2 -4\n", "model.obj:4: position index 0 out of range"},
+		{"v 0 0 0\nv 1 0 0\nv 1 1 0\nf 1/1 2/1 3/1\n", "model.obj:4: texture coordinate index 1 out of range"},
+		{"# comment\nvt 0\n", "model.obj:2: expected at least 2 vector elements, found 1"},
+		{"v 0 0 x\n", "model.obj:1: strconv.ParseFloat"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseOBJ(strings.NewReader(test.source), "model.obj")
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("expected error containing %q, got %v", test.err, err)
+		}
+	}
+}
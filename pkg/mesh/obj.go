@@ -3,6 +3,7 @@ package mesh
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
@@ -35,7 +36,7 @@ func parseVector3(fields []string) (lm.Vector3, error) {
 
 func parseVector2(fields []string) (lm.Vector2, error) {
 	if len(fields) < 2 {
-		return lm.Vector2{}, fmt.Errorf("expected at least 3 vector elements, found %d", len(fields))
+		return lm.Vector2{}, fmt.Errorf("expected at least 2 vector elements, found %d", len(fields))
 	}
 
 	x, err := strconv.ParseFloat(fields[0], 32)
@@ -105,38 +106,93 @@ type objParser struct {
 	texcoords []lm.Vector2
 	normals   []lm.Vector3
 
-	mesh Mesh
+	// Faces are split into meshes by object, group and material, in the
+	// order the combinations first appear
+	object   string
+	group    string
+	material string
+	meshes   map[string]int
+	model    Model
 }
 
-func (o *objParser) vertexAt(triplet Triplet) Vertex {
-	texcoord := lm.Vector2{}
-	normal := lm.Vector3{}
+// resolveIndex converts a 1-based index, or a negative index relative to the
+// end of the list, to a 0-based one
+func resolveIndex(index, count int, kind string) (int, error) {
+	if index < 0 {
+		index += count + 1
+	}
+
+	if index < 1 || index > count {
+		return 0, fmt.Errorf("%s index %d out of range", kind, index)
+	}
+
+	return index - 1, nil
+}
+
+func (o *objParser) vertexAt(triplet Triplet) (Vertex, error) {
+	vertex := Vertex{}
+
+	index, err := resolveIndex(triplet.positionIndex, len(o.positions), "position")
+	if err != nil {
+		return Vertex{}, err
+	}
+
+	vertex.Position = o.positions[index]
 
 	if triplet.texcoordIndex != nil {
-		texcoord = o.texcoords[*triplet.texcoordIndex-1]
+		index, err := resolveIndex(*triplet.texcoordIndex, len(o.texcoords), "texture coordinate")
+		if err != nil {
+			return Vertex{}, err
+		}
+
+		vertex.Texcoord = o.texcoords[index]
 	}
 
 	if triplet.normalIndex != nil {
-		normal = o.normals[*triplet.normalIndex-1]
-	}
+		index, err := resolveIndex(*triplet.normalIndex, len(o.normals), "normal")
+		if err != nil {
+			return Vertex{}, err
+		}
 
-	return Vertex{
-		Position: o.positions[triplet.positionIndex-1],
-		Texcoord: texcoord,
-		Normal:   normal,
+		vertex.Normal = o.normals[index]
 	}
+
+	return vertex, nil
 }
 
-func (o *objParser) triangulatePolygon(triplets []Triplet) []Vertex {
+func (o *objParser) triangulatePolygon(triplets []Triplet) ([]Vertex, error) {
+	polygon := make([]Vertex, len(triplets))
+
+	for i, triplet := range triplets {
+		vertex, err := o.vertexAt(triplet)
+		if err != nil {
+			return nil, err
+		}
+
+		polygon[i] = vertex
+	}
+
 	vertices := []Vertex{}
 
-	origin := o.vertexAt(triplets[0])
+	for i := 2; i < len(polygon); i++ {
+		vertices = append(vertices, polygon[0], polygon[i-1], polygon[i])
+	}
+
+	return vertices, nil
+}
 
-	for i := 2; i < len(triplets); i++ {
-		vertices = append(vertices, origin, o.vertexAt(triplets[i-1]), o.vertexAt(triplets[i]))
+// currentMesh returns the mesh faces are added to
+func (o *objParser) currentMesh() *Mesh {
+	key := o.object + "\x00" + o.group + "\x00" + o.material
+
+	index, ok := o.meshes[key]
+	if !ok {
+		index = len(o.model.Meshes)
+		o.meshes[key] = index
+		o.model.Meshes = append(o.model.Meshes, NewMesh())
 	}
 
-	return vertices
+	return &o.model.Meshes[index]
 }
 
 func (o *objParser) processLine(line string) error {
@@ -183,47 +239,70 @@ func (o *objParser) processLine(line string) error {
 			return err
 		}
 
-		vertices := o.triangulatePolygon(triplets)
+		vertices, err := o.triangulatePolygon(triplets)
+		if err != nil {
+			return err
+		}
 
-		o.mesh.Vertices = append(o.mesh.Vertices, vertices...)
-	}
+		mesh := o.currentMesh()
+		mesh.Vertices = append(mesh.Vertices, vertices...)
 
-	return nil
-}
+	case "o":
+		o.object = strings.Join(fields[1:], " ")
+		o.group = ""
 
-func LoadOBJ(path string) (Model, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return Model{}, err
+	case "g":
+		o.group = strings.Join(fields[1:], " ")
+
+	case "usemtl":
+		o.material = strings.Join(fields[1:], " ")
 	}
 
-	defer file.Close()
+	return nil
+}
 
-	scanner := bufio.NewScanner(file)
+// ParseOBJ reads a Wavefront OBJ model. Every combination of an object, a
+// group and a material becomes a separate mesh, which is how tiles are
+// assigned to them.
+func ParseOBJ(r io.Reader, name string) (Model, error) {
+	scanner := bufio.NewScanner(r)
 	parser := objParser{
 		positions: []lm.Vector3{},
 		texcoords: []lm.Vector2{},
 		normals:   []lm.Vector3{},
-		mesh:      NewMesh(),
+		meshes:    map[string]int{},
+		model:     NewModel(),
 	}
 
-	lineNumber := 1
+	lineNumber := 0
 
 	for scanner.Scan() {
 		lineNumber += 1
 
 		err := parser.processLine(scanner.Text())
 		if err != nil {
-			return Model{}, err
+			return Model{}, fmt.Errorf("%s:%d: %w", name, lineNumber, err)
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
+		return Model{}, fmt.Errorf("%s: %w", name, err)
+	}
+
+	for i := range parser.model.Meshes {
+		parser.model.Meshes[i].fillFaceNormals()
+	}
+
+	return parser.model, nil
+}
+
+func LoadOBJ(path string) (Model, error) {
+	file, err := os.Open(path)
+	if err != nil {
 		return Model{}, err
 	}
 
-	model := NewModel()
-	model.Meshes = append(model.Meshes, parser.mesh)
+	defer file.Close()
 
-	return model, nil
+	return ParseOBJ(file, path)
 }
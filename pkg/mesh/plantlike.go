@@ -0,0 +1,87 @@
+package mesh
+
+import "github.com/lord-server/panorama/pkg/lm"
+
+// PlantStyle selects how plantlike quads are arranged, values match the
+// meshoptions param2 of Minetest
+type PlantStyle uint8
+
+const (
+	PlantStyleCross PlantStyle = iota
+	PlantStyleCross2
+	PlantStyleStar
+	PlantStyleHash
+	PlantStyleHash2
+)
+
+type plantQuad struct {
+	rotation float64
+	offset   float64
+	topOnly  bool
+}
+
+var plantQuads = map[PlantStyle][]plantQuad{
+	PlantStyleCross:  {{rotation: 46}, {rotation: -44}},
+	PlantStyleCross2: {{rotation: 91}, {rotation: 1}},
+	PlantStyleStar:   {{rotation: 121}, {rotation: 241}, {rotation: 1}},
+	PlantStyleHash: {
+		{rotation: 1, offset: 0.25},
+		{rotation: 91, offset: 0.25},
+		{rotation: 181, offset: 0.25},
+		{rotation: 271, offset: 0.25},
+	},
+	PlantStyleHash2: {
+		{rotation: 1, offset: -0.5, topOnly: true},
+		{rotation: 91, offset: -0.5, topOnly: true},
+		{rotation: 181, offset: -0.5, topOnly: true},
+		{rotation: 271, offset: -0.5, topOnly: true},
+	},
+}
+
+// Plantlike generates vertical quads standing on the bottom of the node. Scale
+// is a half of the quad width, height is relative to the quad width. Angles
+// are not multiples of 45 degrees to prevent quads from being parallel to the
+// view direction.
+func Plantlike(style PlantStyle, scale, height float64) *Model {
+	quads, ok := plantQuads[style]
+	if !ok {
+		quads = plantQuads[PlantStyleCross]
+	}
+
+	top := -0.5 + 2*scale*height
+	mesh := NewMesh()
+
+	for _, quad := range quads {
+		// Top left, top right, bottom right, bottom left
+		corners := [4]lm.Vector3{
+			lm.Vec3(-scale, top, quad.offset),
+			lm.Vec3(scale, top, quad.offset),
+			lm.Vec3(scale, -0.5, 0),
+			lm.Vec3(-scale, -0.5, 0),
+		}
+
+		if !quad.topOnly {
+			corners[2].Z = quad.offset
+			corners[3].Z = quad.offset
+		}
+
+		texcoords := [4]lm.Vector2{
+			lm.Vec2(0, 0),
+			lm.Vec2(1, 0),
+			lm.Vec2(1, min(height, 1)),
+			lm.Vec2(0, min(height, 1)),
+		}
+
+		angle := lm.Radians(quad.rotation)
+
+		for i := range corners {
+			corners[i] = corners[i].RotateXZ(angle)
+		}
+
+		mesh.Vertices = append(mesh.Vertices, TexturedQuad(corners, texcoords)...)
+	}
+
+	return &Model{
+		Meshes: []Mesh{mesh},
+	}
+}
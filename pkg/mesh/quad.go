@@ -0,0 +1,38 @@
+package mesh
+
+import "github.com/lord-server/panorama/pkg/lm"
+
+// Quad returns two triangles covering a quad with the whole texture. Corners
+// are ordered as top left, top right, bottom right and bottom left corners of
+// the texture.
+func Quad(corners [4]lm.Vector3) []Vertex {
+	texcoords := [4]lm.Vector2{
+		lm.Vec2(0, 0),
+		lm.Vec2(1, 0),
+		lm.Vec2(1, 1),
+		lm.Vec2(0, 1),
+	}
+
+	return TexturedQuad(corners, texcoords)
+}
+
+// TexturedQuad returns two triangles covering a quad with given texture
+// coordinates of its corners
+func TexturedQuad(corners [4]lm.Vector3, texcoords [4]lm.Vector2) []Vertex {
+	normal := corners[1].Sub(corners[0]).Cross(corners[3].Sub(corners[0])).Normalize()
+
+	var vertices [4]Vertex
+
+	for i, corner := range corners {
+		vertices[i] = Vertex{
+			Position: corner,
+			Texcoord: texcoords[i],
+			Normal:   normal,
+		}
+	}
+
+	return []Vertex{
+		vertices[0], vertices[1], vertices[2],
+		vertices[0], vertices[2], vertices[3],
+	}
+}
@@ -0,0 +1,33 @@
+package mesh
+
+import "github.com/lord-server/panorama/pkg/lm"
+
+// railOffset lifts rails above the floor
+const railOffset = 1.0 / 64
+
+// Raillike generates a flat quad lying on the floor, rotated by given angle in
+// degrees. Sloped rails climb towards the top of the texture.
+func Raillike(angle float64, sloped bool) *Model {
+	top := -0.5
+	if sloped {
+		top = 0.5
+	}
+
+	corners := [4]lm.Vector3{
+		lm.Vec3(0.5, top, 0.5),
+		lm.Vec3(-0.5, top, 0.5),
+		lm.Vec3(-0.5, -0.5, -0.5),
+		lm.Vec3(0.5, -0.5, -0.5),
+	}
+
+	for i, corner := range corners {
+		corner = corner.RotateXZ(lm.Radians(angle))
+		corner.Y += railOffset
+
+		corners[i] = corner
+	}
+
+	return &Model{
+		Meshes: []Mesh{{Vertices: Quad(corners)}},
+	}
+}
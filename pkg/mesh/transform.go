@@ -0,0 +1,65 @@
+package mesh
+
+import "github.com/lord-server/panorama/pkg/lm"
+
+// transform is an affine transformation of model nodes
+type transform struct {
+	linear      lm.Matrix3
+	translation lm.Vector3
+}
+
+func identityTransform() transform {
+	return transform{
+		linear: lm.NewMatrix3([9]float64{1, 0, 0, 0, 1, 0, 0, 0, 1}),
+	}
+}
+
+// newTransform composes translation, rotation given by a unit quaternion and
+// scale, applied in reverse order
+func newTransform(translation lm.Vector3, rotation lm.Vector4, scale lm.Vector3) transform {
+	x, y, z, w := rotation.X, rotation.Y, rotation.Z, rotation.W
+
+	rotationMatrix := lm.NewMatrix3([9]float64{
+		1 - 2*(y*y+z*z), 2 * (x*y - z*w), 2 * (x*z + y*w),
+		2 * (x*y + z*w), 1 - 2*(x*x+z*z), 2 * (y*z - x*w),
+		2 * (x*z - y*w), 2 * (y*z + x*w), 1 - 2*(x*x+y*y),
+	})
+
+	scaleMatrix := lm.NewMatrix3([9]float64{scale.X, 0, 0, 0, scale.Y, 0, 0, 0, scale.Z})
+
+	return transform{
+		linear:      rotationMatrix.Mul(&scaleMatrix),
+		translation: translation,
+	}
+}
+
+// then returns a transformation applying the child transformation first and
+// then this one
+func (t transform) then(child transform) transform {
+	return transform{
+		linear:      t.linear.Mul(&child.linear),
+		translation: t.apply(child.translation),
+	}
+}
+
+func (t transform) apply(position lm.Vector3) lm.Vector3 {
+	return t.linear.MulVec(position).Add(t.translation)
+}
+
+// applyNormal transforms the normal by the inverse transpose of the linear
+// part, so it stays perpendicular to surfaces under non-uniform scale. The
+// cofactor matrix is used instead, since it differs only by the determinant.
+func (t transform) applyNormal(normal lm.Vector3) lm.Vector3 {
+	if normal == (lm.Vector3{}) {
+		return normal
+	}
+
+	cofactor := t.linear.Cofactor()
+	result := cofactor.MulVec(normal)
+
+	if t.linear.Determinant() < 0 {
+		result = result.MulScalar(-1)
+	}
+
+	return result.Normalize()
+}
@@ -0,0 +1,97 @@
+package mesh
+
+import "github.com/lord-server/panorama/pkg/lm"
+
+// WallMounted is the direction of the surface a node is attached to, values
+// match the wallmounted param2 of Minetest
+type WallMounted uint8
+
+const (
+	WallMountedCeiling WallMounted = iota
+	WallMountedFloor
+	WallMountedEast
+	WallMountedWest
+	WallMountedNorth
+	WallMountedSouth
+	WallMountedCeilingRotated
+	WallMountedFloorRotated
+)
+
+// Torchlike generates a single quad leaning against the wall. Size is a half
+// of the quad width.
+func Torchlike(wall WallMounted, size float64) *Model {
+	corners := [4]lm.Vector3{
+		lm.Vec3(-size, size, 0),
+		lm.Vec3(size, size, 0),
+		lm.Vec3(size, -size, 0),
+		lm.Vec3(-size, -size, 0),
+	}
+
+	for i, corner := range corners {
+		switch wall {
+		case WallMountedCeiling, WallMountedCeilingRotated:
+			corner.Y += -size + 0.5
+			corner = corner.RotateXZ(lm.Radians(-45))
+		case WallMountedFloor, WallMountedFloorRotated:
+			corner.Y += size - 0.5
+			corner = corner.RotateXZ(lm.Radians(45))
+		case WallMountedEast:
+			corner.X += -size + 0.5
+		case WallMountedWest:
+			corner.X += -size + 0.5
+			corner = corner.RotateXZ(lm.Radians(180))
+		case WallMountedNorth:
+			corner.X += -size + 0.5
+			corner = corner.RotateXZ(lm.Radians(90))
+		case WallMountedSouth:
+			corner.X += -size + 0.5
+			corner = corner.RotateXZ(lm.Radians(-90))
+		}
+
+		corners[i] = corner
+	}
+
+	return &Model{
+		Meshes: []Mesh{{Vertices: Quad(corners)}},
+	}
+}
+
+// signlikeOffset is the distance between the sign and the wall
+const signlikeOffset = 1.0 / 16
+
+// Signlike generates a quad lying flat on the wall. Size is a half of the
+// quad width.
+func Signlike(wall WallMounted, size float64) *Model {
+	corners := [4]lm.Vector3{
+		lm.Vec3(0.5-signlikeOffset, size, size),
+		lm.Vec3(0.5-signlikeOffset, size, -size),
+		lm.Vec3(0.5-signlikeOffset, -size, -size),
+		lm.Vec3(0.5-signlikeOffset, -size, size),
+	}
+
+	for i, corner := range corners {
+		switch wall {
+		case WallMountedCeiling:
+			corner = corner.RotateXY(lm.Radians(90))
+		case WallMountedFloor:
+			corner = corner.RotateXY(lm.Radians(-90))
+		case WallMountedEast:
+		case WallMountedWest:
+			corner = corner.RotateXZ(lm.Radians(180))
+		case WallMountedNorth:
+			corner = corner.RotateXZ(lm.Radians(90))
+		case WallMountedSouth:
+			corner = corner.RotateXZ(lm.Radians(-90))
+		case WallMountedCeilingRotated:
+			corner = corner.RotateXY(lm.Radians(90)).RotateXZ(lm.Radians(90))
+		case WallMountedFloorRotated:
+			corner = corner.RotateXY(lm.Radians(-90)).RotateXZ(lm.Radians(-90))
+		}
+
+		corners[i] = corner
+	}
+
+	return &Model{
+		Meshes: []Mesh{{Vertices: Quad(corners)}},
+	}
+}